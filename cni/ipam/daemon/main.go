@@ -0,0 +1,256 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Azure/azure-container-networking/cni"
+	cniIpam "github.com/Azure/azure-container-networking/cni/ipam"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Daemon name as used in log names.
+	name = "azure-vnet-ipamd"
+
+	// Store name shared with the CNI IPAM plugin, so that state carries over between modes.
+	storeName = "azure-vnet-ipam"
+)
+
+// Version is populated by make during build.
+var version string
+
+// Command line arguments for the IPAM daemon.
+var args = common.ArgumentList{
+	{
+		Name:         common.OptEnvironment,
+		Shorthand:    common.OptEnvironmentAlias,
+		Description:  "Set the operating environment",
+		Type:         "string",
+		DefaultValue: common.OptEnvironmentAzure,
+		ValueMap: map[string]interface{}{
+			common.OptEnvironmentAzure: 0,
+			common.OptEnvironmentMAS:   0,
+			common.OptEnvironmentFile:  0,
+		},
+	},
+	{
+		Name:         common.OptAPIServerURL,
+		Shorthand:    common.OptAPIServerURLAlias,
+		Description:  "Set the API server URL",
+		Type:         "string",
+		DefaultValue: "unix://" + cniIpam.DaemonSocketPath,
+	},
+	{
+		Name:         common.OptLogLevel,
+		Shorthand:    common.OptLogLevelAlias,
+		Description:  "Set the logging level",
+		Type:         "int",
+		DefaultValue: common.OptLogLevelInfo,
+		ValueMap: map[string]interface{}{
+			common.OptLogLevelInfo:  log.LevelInfo,
+			common.OptLogLevelDebug: log.LevelDebug,
+		},
+	},
+	{
+		Name:         common.OptLogTarget,
+		Shorthand:    common.OptLogTargetAlias,
+		Description:  "Set the logging target",
+		Type:         "int",
+		DefaultValue: common.OptLogTargetFile,
+		ValueMap: map[string]interface{}{
+			common.OptLogTargetSyslog: log.TargetSyslog,
+			common.OptLogTargetStderr: log.TargetStderr,
+			common.OptLogTargetFile:   log.TargetLogfile,
+		},
+	},
+	{
+		Name:         common.OptIpamQueryUrl,
+		Shorthand:    common.OptIpamQueryUrlAlias,
+		Description:  "Set the IPAM query URL",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         common.OptIpamQueryInterval,
+		Shorthand:    common.OptIpamQueryIntervalAlias,
+		Description:  "Set the IPAM plugin query interval",
+		Type:         "int",
+		DefaultValue: "",
+	},
+	{
+		Name:         common.OptIpamConfigFile,
+		Shorthand:    common.OptIpamConfigFileAlias,
+		Description:  "Set the IPAM static configuration file",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         common.OptStoreType,
+		Shorthand:    common.OptStoreTypeAlias,
+		Description:  "Set the persistent store type",
+		Type:         "string",
+		DefaultValue: common.OptStoreTypeJson,
+		ValueMap: map[string]interface{}{
+			common.OptStoreTypeJson: 0,
+			common.OptStoreTypeBolt: 0,
+		},
+	},
+	{
+		Name:         common.OptVersion,
+		Shorthand:    common.OptVersionAlias,
+		Description:  "Print version information",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+}
+
+// Prints description and version information.
+func printVersion() {
+	fmt.Printf("Azure CNI IPAM daemon\n")
+	fmt.Printf("Version %v\n", version)
+}
+
+// Main is the entry point for the IPAM daemon.
+func main() {
+	// Initialize and parse command line arguments.
+	common.ParseArgs(&args, printVersion)
+
+	environment := common.GetArg(common.OptEnvironment).(string)
+	apiServerURL := common.GetArg(common.OptAPIServerURL).(string)
+	logLevel := common.GetArg(common.OptLogLevel).(int)
+	logTarget := common.GetArg(common.OptLogTarget).(int)
+	ipamQueryUrl, _ := common.GetArg(common.OptIpamQueryUrl).(string)
+	ipamQueryInterval, _ := common.GetArg(common.OptIpamQueryInterval).(int)
+	ipamConfigFile, _ := common.GetArg(common.OptIpamConfigFile).(string)
+	vers := common.GetArg(common.OptVersion).(bool)
+	storeType := common.GetArg(common.OptStoreType).(string)
+
+	if vers {
+		printVersion()
+		os.Exit(0)
+	}
+
+	// Initialize daemon common configuration.
+	var config common.PluginConfig
+	config.Version = version
+	config.ErrChan = make(chan error, 1)
+
+	// The base CNI plugin provides the key-value store shared with the CNI IPAM plugin.
+	plugin, err := cni.NewPlugin(storeName, version)
+	if err != nil {
+		fmt.Printf("Failed to create plugin, err:%v.\n", err)
+		return
+	}
+
+	// Create logging provider.
+	log.SetName(name)
+	log.SetLevel(logLevel)
+	err = log.SetTarget(logTarget)
+	if err != nil {
+		fmt.Printf("Failed to configure logging: %v\n", err)
+		return
+	}
+
+	defer log.Close()
+
+	// Log platform information.
+	log.Printf("[ipamd] Daemon version %v.", version)
+	log.Printf("[ipamd] Running on %v", platform.GetOSInfo())
+
+	// Hold the store lock for the lifetime of the daemon.
+	config.StoreType = storeType
+	err = plugin.InitializeKeyValueStore(&config)
+	if err != nil {
+		log.Printf("[ipamd] Failed to initialize key-value store, err:%v.", err)
+		return
+	}
+
+	defer plugin.UninitializeKeyValueStore()
+
+	// Create and initialize the address manager.
+	am, err := ipam.NewAddressManager()
+	if err != nil {
+		log.Printf("[ipamd] Failed to create address manager, err:%v.", err)
+		return
+	}
+
+	options := map[string]interface{}{
+		common.OptEnvironment:       environment,
+		common.OptIpamQueryUrl:      ipamQueryUrl,
+		common.OptIpamQueryInterval: ipamQueryInterval,
+		common.OptIpamConfigFile:    ipamConfigFile,
+	}
+
+	err = am.Initialize(&config, options)
+	if err != nil {
+		log.Printf("[ipamd] Failed to initialize address manager, err:%v.", err)
+		return
+	}
+
+	defer am.Uninitialize()
+
+	am.SetExhaustionHandler(cniIpam.NewExhaustionReporter("AzureCNIIPAMDaemon"))
+
+	// Create the listener.
+	u, err := url.Parse(apiServerURL)
+	if err != nil {
+		log.Printf("[ipamd] Failed to parse API server URL %v, err:%v.", apiServerURL, err)
+		return
+	}
+
+	listener, err := common.NewListener(u)
+	if err != nil {
+		log.Printf("[ipamd] Failed to create listener, err:%v.", err)
+		return
+	}
+
+	_, err = ipam.NewAddressManagerServer(am, listener)
+	if err != nil {
+		log.Printf("[ipamd] Failed to create address manager server, err:%v.", err)
+		return
+	}
+
+	// Remove the socket left behind by a previous instance.
+	if u.Scheme == "unix" {
+		os.Remove(u.Host + u.Path)
+	}
+
+	err = listener.Start(config.ErrChan)
+	if err != nil {
+		log.Printf("[ipamd] Failed to start listener, err:%v.", err)
+		return
+	}
+
+	defer listener.Stop()
+
+	log.Printf("[ipamd] Daemon started.")
+
+	// Release addresses leaked by CNI commands that did not complete before the daemon started.
+	// This waits for CNI commands in progress, which are served by the listener meanwhile.
+	err = cniIpam.ReleaseStaleAddresses(am, cniIpam.NetworkStorePath)
+	if err != nil {
+		log.Printf("[ipamd] Failed to release stale addresses, err:%v.", err)
+	}
+
+	// Relay these incoming signals to OS signal channel.
+	osSignalChannel := make(chan os.Signal, 1)
+	signal.Notify(osSignalChannel, os.Interrupt, os.Kill, syscall.SIGTERM)
+
+	// Wait until receiving a signal.
+	select {
+	case sig := <-osSignalChannel:
+		log.Printf("[ipamd] Received OS signal <%v>, shutting down.", sig)
+	case err := <-config.ErrChan:
+		log.Printf("[ipamd] Received unhandled error %v, shutting down.", err)
+	}
+}
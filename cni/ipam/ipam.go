@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/common"
@@ -22,10 +23,17 @@ import (
 const (
 	// Plugin name.
 	name = "azure-vnet-ipam"
+
+	// DaemonSocketPath is the unix socket on which the IPAM daemon serves address manager requests.
+	DaemonSocketPath = platform.CNIRuntimePath + "azure-vnet-ipam.sock"
+
+	// NetworkStorePath is the store of the CNI network plugin, which records the containers of endpoints.
+	NetworkStorePath = platform.CNIRuntimePath + "azure-vnet"
 )
 
 var (
-	ipv4DefaultRouteDstPrefix = net.IPNet{net.IPv4zero, net.IPv4Mask(0, 0, 0, 0)}
+	ipv4DefaultRouteDstPrefix = net.IPNet{IP: net.IPv4zero, Mask: net.IPv4Mask(0, 0, 0, 0)}
+	ipv6DefaultRouteDstPrefix = net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
 )
 
 // IpamPlugin represents the CNI IPAM plugin.
@@ -59,6 +67,20 @@ func NewPlugin(config *common.PluginConfig) (*ipamPlugin, error) {
 	return ipamPlg, nil
 }
 
+// ConnectToDaemon makes the plugin a thin client of the IPAM daemon, if the daemon is running.
+// In this mode the plugin does not use the key-value store or an address configuration source.
+func (plugin *ipamPlugin) ConnectToDaemon(socketPath string) error {
+	am, err := ipam.NewAddressManagerClient(socketPath)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[cni-ipam] Forwarding requests to IPAM daemon at %v.", socketPath)
+	plugin.am = am
+
+	return nil
+}
+
 // Starts the plugin.
 func (plugin *ipamPlugin) Start(config *common.PluginConfig) error {
 	// Initialize base plugin.
@@ -91,6 +113,12 @@ func (plugin *ipamPlugin) Stop() {
 	log.Printf("[cni-ipam] Plugin stopped.")
 }
 
+// SetExhaustionHandler sets the handler invoked on address pool exhaustion events.
+// It fails when the plugin is a client of the IPAM daemon, which reports exhaustion events itself.
+func (plugin *ipamPlugin) SetExhaustionHandler(handler func(*ipam.ExhaustionEvent)) error {
+	return plugin.am.SetExhaustionHandler(handler)
+}
+
 // Configure parses and applies the given network configuration.
 func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error) {
 	// Parse network configuration from stdin.
@@ -115,6 +143,11 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 		plugin.SetOption(common.OptIpamQueryInterval, i)
 	}
 
+	// Set static configuration file.
+	if nwCfg.Ipam.ConfigFile != "" {
+		plugin.SetOption(common.OptIpamConfigFile, nwCfg.Ipam.ConfigFile)
+	}
+
 	err = plugin.am.StartSource(plugin.Options)
 	if err != nil {
 		return nil, err
@@ -128,6 +161,64 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 	return nwCfg, nil
 }
 
+// Returns the address pool request options for the given network configuration.
+func getPoolOptions(nwCfg *cni.NetworkConfig) map[string]string {
+	// Select the requested interface.
+	options := make(map[string]string)
+	options[ipam.OptInterfaceName] = nwCfg.Master
+
+	// Select the address allocation strategy.
+	if nwCfg.Ipam.AllocStrategy != "" {
+		options[ipam.OptAllocStrategy] = nwCfg.Ipam.AllocStrategy
+	}
+
+	// Select the pool selection policy across interfaces.
+	if nwCfg.Ipam.PoolPolicy != "" {
+		options[ipam.OptPoolPolicy] = nwCfg.Ipam.PoolPolicy
+	}
+
+	// Set the quarantine interval for released addresses.
+	if nwCfg.Ipam.QuarantineInterval != "" {
+		options[ipam.OptQuarantineInterval] = nwCfg.Ipam.QuarantineInterval
+	}
+
+	// Probe for duplicate addresses before allocation.
+	if nwCfg.Ipam.DetectDuplicates {
+		options[ipam.OptDetectDuplicates] = "true"
+	}
+
+	// Exclude reserved addresses from allocation.
+	if len(nwCfg.Ipam.ExcludedAddresses) > 0 {
+		options[ipam.OptExcludedAddresses] = strings.Join(nwCfg.Ipam.ExcludedAddresses, ",")
+	}
+
+	// Report pools whose utilization crosses the high-water mark.
+	if nwCfg.Ipam.HighWaterMark != "" {
+		options[ipam.OptHighWaterMark] = nwCfg.Ipam.HighWaterMark
+	}
+
+	return options
+}
+
+// Returns the address request options for the given network configuration and CNI args.
+func getAddressOptions(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) map[string]string {
+	// Tag the address with the container ID so that leaked addresses can be reclaimed.
+	options := map[string]string{ipam.OptAddressID: args.ContainerID}
+
+	// Reserve the address for the pod identity instead, so that a restarted pod gets the same address back.
+	// The container remains the owner of the address for leak reclamation.
+	if nwCfg.Ipam.ReservationTTL != "" {
+		podCfg, err := cni.ParseCniArgs(args.Args)
+		if err == nil && podCfg.K8S_POD_NAMESPACE != "" && podCfg.K8S_POD_NAME != "" {
+			options[ipam.OptAddressID] = string(podCfg.K8S_POD_NAMESPACE) + "/" + string(podCfg.K8S_POD_NAME)
+			options[ipam.OptAddressOwner] = args.ContainerID
+			options[ipam.OptReservationTTL] = nwCfg.Ipam.ReservationTTL
+		}
+	}
+
+	return options
+}
+
 //
 // CNI implementation
 // https://github.com/containernetworking/cni/blob/master/SPEC.md
@@ -150,17 +241,16 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	// Dual-stack networks get their IPv6 pool along with their IPv4 pool.
+	requestPoolV6 := nwCfg.Ipam.DualStack && nwCfg.Ipam.Subnet == "" && nwCfg.Ipam.SubnetV6 == ""
+
 	// Check if an address pool is specified.
 	if nwCfg.Ipam.Subnet == "" {
 		var poolID string
 		var subnet string
 
-		// Select the requested interface.
-		options := make(map[string]string)
-		options[ipam.OptInterfaceName] = nwCfg.Master
-
 		// Allocate an address pool.
-		poolID, subnet, err = plugin.am.RequestPool(nwCfg.Ipam.AddrSpace, "", "", options, false)
+		poolID, subnet, err = plugin.am.RequestPool(nwCfg.Ipam.AddrSpace, "", "", getPoolOptions(nwCfg), false)
 		if err != nil {
 			err = plugin.Errorf("Failed to allocate pool: %v", err)
 			return err
@@ -179,7 +269,8 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	}
 
 	// Allocate an address for the endpoint.
-	address, err := plugin.am.RequestAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet, nwCfg.Ipam.Address, nil)
+	options := getAddressOptions(nwCfg, args)
+	address, err := plugin.am.RequestAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet, nwCfg.Ipam.Address, options)
 	if err != nil {
 		err = plugin.Errorf("Failed to allocate address: %v", err)
 		return err
@@ -231,6 +322,85 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 		result.DNS.Nameservers = append(result.DNS.Nameservers, dnsServer.String())
 	}
 
+	// Allocate an IPv6 address for dual-stack networks.
+	// Networks created without an IPv6 pool remain IPv4-only, since requesting
+	// their IPv6 pool on each command would take a new reference every time.
+	if nwCfg.Ipam.DualStack && nwCfg.Ipam.SubnetV6 == "" && !requestPoolV6 {
+		log.Printf("[cni-ipam] Network with subnet %v has no IPv6 pool, skipping IPv6 address.", nwCfg.Ipam.Subnet)
+	} else if nwCfg.Ipam.DualStack {
+		var addressV6 string
+		var ipAddressV6 *net.IPNet
+		var apInfoV6 *ipam.AddressPoolInfo
+
+		// Allocate an IPv6 address pool for a new network.
+		if requestPoolV6 {
+			var poolIDV6 string
+			var subnetV6 string
+
+			poolIDV6, subnetV6, err = plugin.am.RequestPool(nwCfg.Ipam.AddrSpace, "", "", getPoolOptions(nwCfg), true)
+			if err != nil {
+				err = plugin.Errorf("Failed to allocate IPv6 pool: %v", err)
+				return err
+			}
+
+			// On failure, release the IPv6 address pool.
+			defer func() {
+				if err != nil && poolIDV6 != "" {
+					log.Printf("[cni-ipam] Releasing pool %v.", poolIDV6)
+					plugin.am.ReleasePool(nwCfg.Ipam.AddrSpace, poolIDV6)
+				}
+			}()
+
+			nwCfg.Ipam.SubnetV6 = subnetV6
+			log.Printf("[cni-ipam] Allocated address poolID %v with subnet %v.", poolIDV6, subnetV6)
+		}
+
+		// IPv6 addresses are tagged with the container ID only, and are not reserved for the pod.
+		optionsV6 := map[string]string{ipam.OptAddressID: args.ContainerID}
+		addressV6, err = plugin.am.RequestAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.SubnetV6, "", optionsV6)
+		if err != nil {
+			err = plugin.Errorf("Failed to allocate IPv6 address: %v", err)
+			return err
+		}
+
+		// On failure, release the IPv6 address.
+		defer func() {
+			if err != nil && addressV6 != "" {
+				log.Printf("[cni-ipam] Releasing address %v.", addressV6)
+				plugin.am.ReleaseAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.SubnetV6, addressV6, nil)
+			}
+		}()
+
+		log.Printf("[cni-ipam] Allocated address %v.", addressV6)
+
+		ipAddressV6, err = platform.ConvertStringToIPNet(addressV6)
+		if err != nil {
+			err = plugin.Errorf("Failed to parse address: %v", err)
+			return err
+		}
+
+		apInfoV6, err = plugin.am.GetPoolInfo(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.SubnetV6)
+		if err != nil {
+			err = plugin.Errorf("Failed to get pool information: %v", err)
+			return err
+		}
+
+		result.IPs = append(result.IPs, &cniTypesCurr.IPConfig{
+			Version: "6",
+			Address: *ipAddressV6,
+			Gateway: apInfoV6.Gateway,
+		})
+
+		result.Routes = append(result.Routes, &cniTypes.Route{
+			Dst: ipv6DefaultRouteDstPrefix,
+			GW:  apInfoV6.Gateway,
+		})
+
+		for _, dnsServer := range apInfoV6.DnsServers {
+			result.DNS.Nameservers = append(result.DNS.Nameservers, dnsServer.String())
+		}
+	}
+
 	// Convert result to the requested CNI version.
 	res, err := result.GetAsVersion(nwCfg.CNIVersion)
 	if err != nil {
@@ -273,8 +443,14 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 
 	// If an address is specified, release that address. Otherwise, release the pool.
 	if nwCfg.Ipam.Address != "" {
+		// IPv6 addresses of dual-stack endpoints belong to the IPv6 pool.
+		subnet := nwCfg.Ipam.Subnet
+		if nwCfg.Ipam.SubnetV6 != "" && net.ParseIP(nwCfg.Ipam.Address).To4() == nil {
+			subnet = nwCfg.Ipam.SubnetV6
+		}
+
 		// Release the address.
-		err := plugin.am.ReleaseAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet, nwCfg.Ipam.Address, nil)
+		err := plugin.am.ReleaseAddress(nwCfg.Ipam.AddrSpace, subnet, nwCfg.Ipam.Address, nil)
 		if err != nil {
 			err = plugin.Errorf("Failed to release address: %v", err)
 			return err
@@ -286,6 +462,15 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 			err = plugin.Errorf("Failed to release pool: %v", err)
 			return err
 		}
+
+		// Release the IPv6 pool of dual-stack networks.
+		if nwCfg.Ipam.SubnetV6 != "" {
+			err = plugin.am.ReleasePool(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.SubnetV6)
+			if err != nil {
+				err = plugin.Errorf("Failed to release IPv6 pool: %v", err)
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -4,13 +4,21 @@
 package ipam
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/store"
+
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
 var plugin *ipamPlugin
@@ -23,6 +31,12 @@ var ipamQueryResponse = "" +
 	"			<IPAddress Address=\"10.0.0.4\" IsPrimary=\"true\"/>" +
 	"			<IPAddress Address=\"10.0.0.5\" IsPrimary=\"false\"/>" +
 	"			<IPAddress Address=\"10.0.0.6\" IsPrimary=\"false\"/>" +
+	"			<IPAddress Address=\"10.0.0.7\" IsPrimary=\"false\"/>" +
+	"		</IPSubnet>" +
+	"		<IPSubnet Prefix=\"ace:cab:deca::/64\">" +
+	"			<IPAddress Address=\"ace:cab:deca::4\" IsPrimary=\"true\"/>" +
+	"			<IPAddress Address=\"ace:cab:deca::5\" IsPrimary=\"false\"/>" +
+	"			<IPAddress Address=\"ace:cab:deca::6\" IsPrimary=\"false\"/>" +
 	"		</IPSubnet>" +
 	"	</Interface>" +
 	"</Interfaces>"
@@ -94,3 +108,139 @@ func TestAddSuccess(t *testing.T) {
 
 func TestDelSuccess(t *testing.T) {
 }
+
+// Tests that dual-stack networks take one reference to their IPv6 pool, and that IPv6 addresses are not reserved for pods.
+func TestAddDualStack(t *testing.T) {
+	asId := ipam.LocalDefaultAddressSpaceId
+
+	add := func(nwCfg *cni.NetworkConfig, containerID string, cniArgs string) *cniTypesCurr.Result {
+		nwCfg.CNIVersion = "0.3.0"
+		nwCfg.Ipam.Type = cni.Internal
+		nwCfg.Ipam.DualStack = true
+		stdinData, _ := json.Marshal(nwCfg)
+
+		args := &cniSkel.CmdArgs{ContainerID: containerID, Args: cniArgs, StdinData: stdinData}
+		if err := plugin.Add(args); err != nil {
+			t.Fatalf("Add failed, err:%v", err)
+		}
+
+		var result cniTypesCurr.Result
+		if err := json.Unmarshal(args.StdinData, &result); err != nil {
+			t.Fatalf("Unmarshal failed, err:%v", err)
+		}
+
+		return &result
+	}
+
+	// A new network gets an address from each address family.
+	var nwCfg cni.NetworkConfig
+	result := add(&nwCfg, "dual1", "")
+	if len(result.IPs) != 2 || result.IPs[1].Version != "6" {
+		t.Fatalf("Add returned invalid addresses %+v.", result.IPs)
+	}
+
+	subnet := "10.0.0.0/16"
+	subnetV6 := "ace:cab:deca::/64"
+	defer plugin.am.ReleasePool(asId, subnet)
+
+	// A network without an IPv6 pool gets only an IPv4 address.
+	nwCfg = cni.NetworkConfig{}
+	nwCfg.Ipam.Subnet = subnet
+	result = add(&nwCfg, "dual2", "")
+	if len(result.IPs) != 1 || result.IPs[0].Version != "4" {
+		t.Errorf("Add returned invalid addresses %+v.", result.IPs)
+	}
+
+	// IPv6 addresses are tagged with the container ID, even if the IPv4 address is reserved for the pod.
+	nwCfg = cni.NetworkConfig{}
+	nwCfg.Ipam.Subnet = subnet
+	nwCfg.Ipam.SubnetV6 = subnetV6
+	nwCfg.Ipam.ReservationTTL = "60"
+	result = add(&nwCfg, "dual3", "K8S_POD_NAMESPACE=default;K8S_POD_NAME=web-0")
+	if len(result.IPs) != 2 {
+		t.Fatalf("Add returned invalid addresses %+v.", result.IPs)
+	}
+
+	for _, id := range []string{"dual1", "dual3"} {
+		err := plugin.am.ReleaseAddress(asId, subnetV6, "", map[string]string{ipam.OptAddressID: id})
+		if err != nil {
+			t.Errorf("ReleaseAddress for %v failed, err:%v", id, err)
+		}
+	}
+
+	for _, id := range []string{"dual1", "dual2", "default/web-0"} {
+		plugin.am.ReleaseAddress(asId, subnet, "", map[string]string{ipam.OptAddressID: id})
+	}
+
+	// The IPv6 pool was requested once.
+	if err := plugin.am.ReleasePool(asId, subnetV6); err != nil {
+		t.Errorf("ReleasePool failed, err:%v", err)
+	}
+
+	if err := plugin.am.ReleasePool(asId, subnetV6); err == nil {
+		t.Errorf("IPv6 pool was requested more than once.")
+	}
+}
+
+// Tests that addresses of containers without endpoints in the network store are released.
+func TestReleaseStaleAddresses(t *testing.T) {
+	storePath := "/tmp/azure-vnet-test"
+	defer os.Remove(storePath + ".json")
+
+	asId := ipam.LocalDefaultAddressSpaceId
+	poolId, _, err := plugin.am.RequestPool(asId, "", "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+	defer plugin.am.ReleasePool(asId, poolId)
+
+	var addresses []string
+	for _, id := range []string{"live", "leaked"} {
+		address, err := plugin.am.RequestAddress(asId, poolId, "", map[string]string{ipam.OptAddressID: id})
+		if err != nil {
+			t.Fatalf("RequestAddress failed, err:%v", err)
+		}
+		defer plugin.am.ReleaseAddress(asId, poolId, "", map[string]string{ipam.OptAddressID: id})
+
+		ip, _, _ := net.ParseCIDR(address)
+		addresses = append(addresses, ip.String())
+	}
+
+	// Returns whether an address is available to other containers.
+	isAvailable := func(address string) bool {
+		options := map[string]string{ipam.OptAddressID: "other"}
+		if _, err := plugin.am.RequestAddress(asId, poolId, address, options); err != nil {
+			return false
+		}
+		plugin.am.ReleaseAddress(asId, poolId, address, options)
+		return true
+	}
+
+	// Nothing is released without network state.
+	if err = ReleaseStaleAddresses(plugin.am, storePath); err != nil || isAvailable(addresses[1]) {
+		t.Errorf("ReleaseStaleAddresses without network state released addresses, err:%v", err)
+	}
+
+	kvs, err := store.NewJsonFileStore(storePath + ".json")
+	if err != nil {
+		t.Fatalf("NewJsonFileStore failed, err:%v", err)
+	}
+
+	state := `{"SchemaVersion":1,"ExternalInterfaces":{"eth0":{"Networks":{"azure":{"Endpoints":{` +
+		`"12345678-eth0":{"Id":"12345678-eth0","ContainerID":"live"}}}}}}}`
+	if err = kvs.Write("Network", json.RawMessage(state)); err != nil {
+		t.Fatalf("Write failed, err:%v", err)
+	}
+
+	if err = ReleaseStaleAddresses(plugin.am, storePath); err != nil {
+		t.Fatalf("ReleaseStaleAddresses failed, err:%v", err)
+	}
+
+	if isAvailable(addresses[0]) {
+		t.Errorf("Address %v of live container released.", addresses[0])
+	}
+
+	if !isAvailable(addresses[1]) {
+		t.Errorf("Address %v of exited container not released.", addresses[1])
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/ipam"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
 )
 
 // Version is populated by make during build.
@@ -26,9 +27,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := ipamPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
-		fmt.Printf("Failed to initialize key-value store of ipam plugin, err:%v.\n", err)
-		os.Exit(1)
+	// Forward requests to the IPAM daemon if it is running.
+	// Otherwise, manage addresses in this process using the key-value store.
+	if err := ipamPlugin.ConnectToDaemon(ipam.DaemonSocketPath); err != nil {
+		if err := ipamPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
+			fmt.Printf("Failed to initialize key-value store of ipam plugin, err:%v.\n", err)
+			os.Exit(1)
+		}
 	}
 
 	defer func() {
@@ -47,6 +52,11 @@ func main() {
 		panic("ipam plugin fatal error")
 	}
 
+	// Report exhaustion events, unless the IPAM daemon reports them.
+	if err := ipamPlugin.SetExhaustionHandler(ipam.NewExhaustionReporter("AzureCNIIPAM")); err != nil {
+		log.Printf("[cni-ipam] Exhaustion events are not reported by the plugin, err:%v.", err)
+	}
+
 	err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
 
 	ipamPlugin.Stop()
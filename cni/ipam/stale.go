@@ -0,0 +1,49 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// ReleaseStaleAddresses releases the addresses of containers that have no endpoint in the store of the CNI
+// network plugin, such as those left behind by DEL commands that did not complete after a node crash.
+// The network store stays locked meanwhile, so that no ADD or DEL command is in progress.
+// Nothing is released if the network store has no state, since addresses may then be used by another
+// network plugin, or if the containers of some endpoints are not known.
+func ReleaseStaleAddresses(am ipam.AddressManager, networkStorePath string) error {
+	kvs, err := store.NewStore(store.DetectStoreType(networkStorePath), networkStorePath)
+	if err != nil {
+		return err
+	}
+
+	if err = kvs.Lock(true); err != nil {
+		return err
+	}
+
+	defer kvs.Unlock(false)
+
+	containerIDs, err := network.GetEndpointContainerIDs(kvs)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			log.Printf("[cni-ipam] Not releasing stale addresses, network store %v has no state.", networkStorePath)
+			return nil
+		}
+		return err
+	}
+
+	reclaimed, err := am.ReleaseStaleAddresses("", containerIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range reclaimed {
+		log.Printf("[cni-ipam] Released stale address %v of %v in pool %v.", info.Address, info.ID, info.PoolId)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// NewExhaustionReporter returns an exhaustion handler that sends address pool exhaustion
+// events to the telemetry service, if it is running, tagged with the given context.
+func NewExhaustionReporter(context string) func(*ipam.ExhaustionEvent) {
+	return func(event *ipam.ExhaustionEvent) {
+		tb := telemetry.NewTelemetryBuffer("")
+		tb.TryToConnectToTelemetryService()
+		defer tb.Close()
+
+		reportManager := &telemetry.ReportManager{
+			ContentType: telemetry.ContentType,
+			Report:      newExhaustionReport(context, event),
+		}
+
+		if err := reportManager.SendReport(tb); err != nil {
+			log.Printf("[cni-ipam] Failed to send exhaustion report, err:%v.", err)
+		}
+	}
+}
+
+// Creates a telemetry report from an address pool exhaustion event.
+func newExhaustionReport(context string, event *ipam.ExhaustionEvent) *telemetry.IPAMReport {
+	report := &telemetry.IPAMReport{
+		Context:          context,
+		ExhaustionReason: event.Reason,
+		AddressSpace:     event.AsId,
+		PoolID:           event.PoolId,
+		HighWaterMark:    event.HighWaterMark,
+		Timestamp:        event.Timestamp.UTC().String(),
+		EventMessage:     fmt.Sprintf("Address pool %v %v", event.PoolId, event.Reason),
+	}
+
+	if event.Metrics != nil {
+		report.Capacity = event.Metrics.Capacity
+		report.InUse = event.Metrics.InUse
+		report.Unhealthy = event.Metrics.Unhealthy
+		report.Quarantined = event.Metrics.Quarantined
+		report.AllocationRate = event.Metrics.AllocationRate
+		report.ReleaseRate = event.Metrics.ReleaseRate
+	}
+
+	return report
+}
@@ -5,8 +5,10 @@ package cni
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
+	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/policy"
 
 	cniTypes "github.com/containernetworking/cni/pkg/types"
@@ -48,6 +50,7 @@ type NetworkConfig struct {
 	Mode                       string   `json:"mode"`
 	Master                     string   `json:"master"`
 	Bridge                     string   `json:"bridge,omitempty"`
+	MTU                        int      `json:"mtu,omitempty"`
 	LogLevel                   string   `json:"logLevel,omitempty"`
 	LogTarget                  string   `json:"logTarget,omitempty"`
 	InfraVnetAddressSpace      string   `json:"infraVnetAddressSpace,omitempty"`
@@ -57,12 +60,22 @@ type NetworkConfig struct {
 	EnableExactMatchForPodName bool     `json:"enableExactMatchForPodName,omitempty"`
 	CNSUrl                     string   `json:"cnsurl,omitempty"`
 	Ipam                       struct {
-		Type          string `json:"type"`
-		Environment   string `json:"environment,omitempty"`
-		AddrSpace     string `json:"addressSpace,omitempty"`
-		Subnet        string `json:"subnet,omitempty"`
-		Address       string `json:"ipAddress,omitempty"`
-		QueryInterval string `json:"queryInterval,omitempty"`
+		Type               string   `json:"type"`
+		Environment        string   `json:"environment,omitempty"`
+		AddrSpace          string   `json:"addressSpace,omitempty"`
+		Subnet             string   `json:"subnet,omitempty"`
+		SubnetV6           string   `json:"subnetV6,omitempty"`
+		Address            string   `json:"ipAddress,omitempty"`
+		QueryInterval      string   `json:"queryInterval,omitempty"`
+		ConfigFile         string   `json:"configFile,omitempty"`
+		AllocStrategy      string   `json:"allocStrategy,omitempty"`
+		QuarantineInterval string   `json:"quarantineInterval,omitempty"`
+		ExcludedAddresses  []string `json:"excludedAddresses,omitempty"`
+		ReservationTTL     string   `json:"reservationTTL,omitempty"`
+		DetectDuplicates   bool     `json:"detectDuplicates,omitempty"`
+		DualStack          bool     `json:"dualStack,omitempty"`
+		HighWaterMark      string   `json:"highWaterMark,omitempty"`
+		PoolPolicy         string   `json:"poolPolicy,omitempty"`
 	}
 	DNS            cniTypes.DNS  `json:"dns"`
 	RuntimeConfig  RuntimeConfig `json:"runtimeConfig"`
@@ -100,6 +113,11 @@ func ParseNetworkConfig(b []byte) (*NetworkConfig, error) {
 		nwCfg.CNIVersion = defaultVersion
 	}
 
+	// Zero selects the default MTU of the network.
+	if nwCfg.MTU != 0 && nwCfg.MTU < network.MinMTU {
+		return nil, fmt.Errorf("Invalid MTU %d, the minimum is %d", nwCfg.MTU, network.MinMTU)
+	}
+
 	return &nwCfg, nil
 }
 
@@ -26,8 +26,9 @@ const (
 	name                = "azure-vnet"
 	dockerNetworkOption = "com.docker.network.generic"
 	opModeTransparent   = "transparent"
-	// Supported IP version. Currently support only IPv4
-	ipVersion = "4"
+	// Supported IP versions. IPv6 is used only by dual-stack networks.
+	ipVersion   = "4"
+	ipv6Version = "6"
 )
 
 // CNI Operation Types
@@ -138,6 +139,28 @@ func GetEndpointID(args *cniSkel.CmdArgs) string {
 	return infraEpId
 }
 
+// Returns the IPv6 address configuration of a dual-stack IPAM result, if any.
+func getIPv6Config(result *cniTypesCurr.Result) *cniTypesCurr.IPConfig {
+	for _, ipconfig := range result.IPs {
+		if ipconfig.Address.IP.To4() == nil {
+			return ipconfig
+		}
+	}
+
+	return nil
+}
+
+// Returns the IPv6 subnet prefix of a dual-stack network, if any.
+func getIPv6Subnet(nwInfo *network.NetworkInfo) string {
+	for _, subnet := range nwInfo.Subnets {
+		if subnet.Family == platform.AfINET6 {
+			return subnet.Prefix.String()
+		}
+	}
+
+	return ""
+}
+
 // getPodInfo returns POD info by parsing the CNI args.
 func (plugin *netPlugin) getPodInfo(args string) (string, string, error) {
 	podCfg, err := cni.ParseCniArgs(args)
@@ -336,10 +359,24 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		ipconfig := result.IPs[0]
 		gateway := ipconfig.Gateway
 
+		// Dual-stack networks also get an IPv6 subnet.
+		ipconfigV6 := getIPv6Config(result)
+		var subnetPrefixV6 net.IPNet
+		if ipconfigV6 != nil {
+			subnetPrefixV6 = ipconfigV6.Address
+			subnetPrefixV6.IP = subnetPrefixV6.IP.Mask(subnetPrefixV6.Mask)
+		}
+
 		// On failure, call into IPAM plugin to release the address and address pool.
 		defer func() {
 			if err != nil {
 				nwCfg.Ipam.Subnet = subnetPrefix.String()
+				if ipconfigV6 != nil {
+					nwCfg.Ipam.SubnetV6 = subnetPrefixV6.String()
+					nwCfg.Ipam.Address = ipconfigV6.Address.IP.String()
+					plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+				}
+
 				nwCfg.Ipam.Address = ipconfig.Address.IP.String()
 				plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
 
@@ -391,10 +428,19 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 			},
 			BridgeName:       nwCfg.Bridge,
 			EnableSnatOnHost: nwCfg.EnableSnatOnHost,
+			MTU:              nwCfg.MTU,
 			DNS:              nwDNSInfo,
 			Policies:         policies,
 		}
 
+		if ipconfigV6 != nil {
+			nwInfo.Subnets = append(nwInfo.Subnets, network.SubnetInfo{
+				Family:  platform.AfINET6,
+				Prefix:  subnetPrefixV6,
+				Gateway: ipconfigV6.Gateway,
+			})
+		}
+
 		nwInfo.Options = make(map[string]interface{})
 		setNetworkOptions(cnsNetworkConfig, &nwInfo)
 
@@ -411,6 +457,7 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 			subnetPrefix := nwInfo.Subnets[0].Prefix.String()
 			log.Printf("[cni-net] Found network %v with subnet %v.", networkId, subnetPrefix)
 			nwCfg.Ipam.Subnet = subnetPrefix
+			nwCfg.Ipam.SubnetV6 = getIPv6Subnet(nwInfo)
 
 			// Call into IPAM plugin to allocate an address for the endpoint.
 			result, err = plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
@@ -419,15 +466,16 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 				return err
 			}
 
-			ipconfig := result.IPs[0]
 			iface := &cniTypesCurr.Interface{Name: args.IfName}
 			result.Interfaces = append(result.Interfaces, iface)
 
-			// On failure, call into IPAM plugin to release the address.
+			// On failure, call into IPAM plugin to release the addresses.
 			defer func() {
 				if err != nil {
-					nwCfg.Ipam.Address = ipconfig.Address.IP.String()
-					plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+					for _, ipconfig := range result.IPs {
+						nwCfg.Ipam.Address = ipconfig.Address.IP.String()
+						plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+					}
 				}
 			}()
 		}
@@ -453,6 +501,7 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		PODName:            k8sPodName,
 		PODNameSpace:       k8sNamespace,
 		SkipHotAttachEp:    false, // Hot attach at the time of endpoint creation
+		MTU:                nwCfg.MTU,
 	}
 
 	epPolicies := getPoliciesFromRuntimeCfg(nwCfg)
@@ -580,6 +629,10 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 			Address:   ipAddresses,
 		}
 
+		if ipAddresses.IP.To4() == nil {
+			ipConfig.Version = ipv6Version
+		}
+
 		if epInfo.Gateways != nil {
 			ipConfig.Gateway = epInfo.Gateways[0]
 		}
@@ -661,6 +714,7 @@ func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 	if !nwCfg.MultiTenancy {
 		// Call into IPAM plugin to release the endpoint's addresses.
 		nwCfg.Ipam.Subnet = nwInfo.Subnets[0].Prefix.String()
+		nwCfg.Ipam.SubnetV6 = getIPv6Subnet(nwInfo)
 		for _, address := range epInfo.IPAddresses {
 			nwCfg.Ipam.Address = address.IP.String()
 			err = plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
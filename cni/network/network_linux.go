@@ -58,6 +58,11 @@ func setEndpointOptions(cnsNwConfig *cns.GetNetworkContainerResponse, epInfo *ne
 		epInfo.AllowInboundFromNCToHost = cnsNwConfig.AllowNCToHostCommunication
 	}
 
+	// Network containers may require a different MTU than the network.
+	if cnsNwConfig != nil && cnsNwConfig.MTU > 0 {
+		epInfo.MTU = cnsNwConfig.MTU
+	}
+
 	epInfo.Data[network.OptVethName] = vethName
 }
 
@@ -164,7 +164,16 @@ func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error
 	// Create the key value store.
 	if plugin.Store == nil {
 		var err error
-		plugin.Store, err = store.NewJsonFileStore(platform.CNIRuntimePath + plugin.Name + ".json")
+		storePath := platform.CNIRuntimePath + plugin.Name
+
+		// Plugins invoked by the container runtime have no store type setting, since the store
+		// is opened before the network configuration is read. They use the existing store type.
+		storeType := config.StoreType
+		if storeType == "" {
+			storeType = store.DetectStoreType(storePath)
+		}
+
+		plugin.Store, err = store.NewStore(storeType, storePath)
 		if err != nil {
 			log.Printf("[cni] Failed to create store: %v.", err)
 			return err
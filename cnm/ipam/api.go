@@ -3,6 +3,10 @@
 
 package ipam
 
+import (
+	"github.com/Azure/azure-container-networking/ipam"
+)
+
 const (
 	// Libnetwork IPAM plugin endpoint type
 	EndpointType = "IpamDriver"
@@ -15,6 +19,7 @@ const (
 	GetPoolInfoPath      = "/IpamDriver.GetPoolInfo"
 	RequestAddressPath   = "/IpamDriver.RequestAddress"
 	ReleaseAddressPath   = "/IpamDriver.ReleaseAddress"
+	GetMetricsPath       = "/IpamDriver.GetMetrics"
 
 	// Libnetwork IPAM plugin options
 	OptAddressType        = "RequestAddressType"
@@ -108,3 +113,14 @@ type ReleaseAddressRequest struct {
 type ReleaseAddressResponse struct {
 	Err string
 }
+
+// Request sent when querying address space utilization metrics.
+type GetMetricsRequest struct {
+	AddressSpace string
+}
+
+// Response sent by plugin when returning address space utilization metrics.
+type GetMetricsResponse struct {
+	Err           string
+	AddressSpaces []*ipam.AddressSpaceMetrics
+}
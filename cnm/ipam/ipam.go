@@ -81,6 +81,7 @@ func (plugin *ipamPlugin) Start(config *common.PluginConfig) error {
 	listener.AddHandler(GetPoolInfoPath, plugin.getPoolInfo)
 	listener.AddHandler(RequestAddressPath, plugin.requestAddress)
 	listener.AddHandler(ReleaseAddressPath, plugin.releaseAddress)
+	listener.AddHandler(GetMetricsPath, plugin.getMetrics)
 
 	// Plugin is ready to be discovered.
 	err = plugin.EnableDiscovery()
@@ -159,8 +160,14 @@ func (plugin *ipamPlugin) requestPool(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Encode response.
+	// Child pools delegated for a sub-pool are identified by their parent pool and keep its subnet.
 	data := make(map[string]string)
-	poolId = ipam.NewAddressPoolId(req.AddressSpace, poolId, "").String()
+	if req.SubPool != "" {
+		poolId = ipam.NewAddressPoolId(req.AddressSpace, req.Pool, poolId).String()
+		subnet = req.Pool
+	} else {
+		poolId = ipam.NewAddressPoolId(req.AddressSpace, poolId, "").String()
+	}
 	resp := RequestPoolResponse{PoolID: poolId, Pool: subnet, Data: data}
 
 	err = plugin.Listener.Encode(w, &resp)
@@ -186,7 +193,7 @@ func (plugin *ipamPlugin) releasePool(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = plugin.am.ReleasePool(poolId.AsId, poolId.Subnet)
+	err = plugin.am.ReleasePool(poolId.AsId, poolId.PoolId())
 	if err != nil {
 		plugin.SendErrorResponse(w, err)
 		return
@@ -218,7 +225,7 @@ func (plugin *ipamPlugin) getPoolInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apInfo, err := plugin.am.GetPoolInfo(poolId.AsId, poolId.Subnet)
+	apInfo, err := plugin.am.GetPoolInfo(poolId.AsId, poolId.PoolId())
 	if err != nil {
 		plugin.SendErrorResponse(w, err)
 		return
@@ -265,7 +272,11 @@ func (plugin *ipamPlugin) requestAddress(w http.ResponseWriter, r *http.Request)
 
 	options[ipam.OptAddressID] = req.Options[ipam.OptAddressID]
 
-	addr, err := plugin.am.RequestAddress(poolId.AsId, poolId.Subnet, req.Address, options)
+	if leaseDuration, ok := req.Options[ipam.OptLeaseDuration]; ok {
+		options[ipam.OptLeaseDuration] = leaseDuration
+	}
+
+	addr, err := plugin.am.RequestAddress(poolId.AsId, poolId.PoolId(), req.Address, options)
 	if err != nil {
 		plugin.SendErrorResponse(w, err)
 		return
@@ -298,7 +309,7 @@ func (plugin *ipamPlugin) releaseAddress(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = plugin.am.ReleaseAddress(poolId.AsId, poolId.Subnet, req.Address, req.Options)
+	err = plugin.am.ReleaseAddress(poolId.AsId, poolId.PoolId(), req.Address, req.Options)
 	if err != nil {
 		plugin.SendErrorResponse(w, err)
 		return
@@ -311,3 +322,29 @@ func (plugin *ipamPlugin) releaseAddress(w http.ResponseWriter, r *http.Request)
 
 	log.Response(plugin.Name, &resp, returnCode, returnStr, err)
 }
+
+// Handles GetMetrics requests.
+func (plugin *ipamPlugin) getMetrics(w http.ResponseWriter, r *http.Request) {
+	var req GetMetricsRequest
+
+	// Decode request.
+	err := plugin.Listener.Decode(w, r, &req)
+	log.Request(plugin.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	// Process request.
+	metrics, err := plugin.am.GetMetrics(req.AddressSpace)
+	if err != nil {
+		plugin.SendErrorResponse(w, err)
+		return
+	}
+
+	// Encode response.
+	resp := GetMetricsResponse{AddressSpaces: metrics}
+
+	err = plugin.Listener.Encode(w, &resp)
+
+	log.Response(plugin.Name, &resp, returnCode, returnStr, err)
+}
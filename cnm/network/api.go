@@ -19,6 +19,7 @@ const (
 
 	// Libnetwork network plugin options
 	modeOption = "com.microsoft.azure.network.mode"
+	mtuOption  = "com.docker.network.driver.mtu"
 )
 
 // Request sent by libnetwork when querying plugin capabilities.
@@ -36,6 +36,7 @@ var args = common.ArgumentList{
 		ValueMap: map[string]interface{}{
 			common.OptEnvironmentAzure: 0,
 			common.OptEnvironmentMAS:   0,
+			common.OptEnvironmentFile:  0,
 		},
 	},
 	{
@@ -89,6 +90,24 @@ var args = common.ArgumentList{
 		Type:         "int",
 		DefaultValue: "",
 	},
+	{
+		Name:         common.OptIpamConfigFile,
+		Shorthand:    common.OptIpamConfigFileAlias,
+		Description:  "Set the IPAM static configuration file",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         common.OptStoreType,
+		Shorthand:    common.OptStoreTypeAlias,
+		Description:  "Set the persistent store type",
+		Type:         "string",
+		DefaultValue: common.OptStoreTypeJson,
+		ValueMap: map[string]interface{}{
+			common.OptStoreTypeJson: 0,
+			common.OptStoreTypeBolt: 0,
+		},
+	},
 	{
 		Name:         common.OptVersion,
 		Shorthand:    common.OptVersionAlias,
@@ -115,7 +134,9 @@ func main() {
 	logTarget := common.GetArg(common.OptLogTarget).(int)
 	ipamQueryUrl, _ := common.GetArg(common.OptIpamQueryUrl).(string)
 	ipamQueryInterval, _ := common.GetArg(common.OptIpamQueryInterval).(int)
+	ipamConfigFile, _ := common.GetArg(common.OptIpamConfigFile).(string)
 	vers := common.GetArg(common.OptVersion).(bool)
+	storeType := common.GetArg(common.OptStoreType).(string)
 
 	if vers {
 		printVersion()
@@ -150,7 +171,7 @@ func main() {
 	}
 
 	// Create the key value store.
-	config.Store, err = store.NewJsonFileStore(platform.CNMRuntimePath + name + ".json")
+	config.Store, err = store.NewStore(storeType, platform.CNMRuntimePath+name)
 	if err != nil {
 		fmt.Printf("Failed to create store: %v\n", err)
 		return
@@ -176,6 +197,7 @@ func main() {
 	ipamPlugin.SetOption(common.OptAPIServerURL, url)
 	ipamPlugin.SetOption(common.OptIpamQueryUrl, ipamQueryUrl)
 	ipamPlugin.SetOption(common.OptIpamQueryInterval, ipamQueryInterval)
+	ipamPlugin.SetOption(common.OptIpamConfigFile, ipamConfigFile)
 
 	// Start plugins.
 	if netPlugin != nil {
@@ -58,6 +58,7 @@ type CreateNetworkContainerRequest struct {
 	Routes                     []Route
 	AllowHostToNCCommunication bool
 	AllowNCToHostCommunication bool
+	MTU                        int `json:",omitempty"` // Zero for the MTU of the container network.
 }
 
 // ConfigureContainerNetworkingRequest - specifies request to attach/detach container to network.
@@ -139,6 +140,7 @@ type GetNetworkContainerResponse struct {
 	Response                   Response
 	AllowHostToNCCommunication bool
 	AllowNCToHostCommunication bool
+	MTU                        int `json:",omitempty"`
 }
 
 // DeleteNetworkContainerRequest specifies the details about the request to delete a specifc network container.
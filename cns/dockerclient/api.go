@@ -8,6 +8,11 @@ const (
 	inspectNetworkPath = "/networks/"
 
 	OptDisableSnat = "DisableSNAT"
+	OptVxlanID     = "VxlanID"
+
+	// Generic network options of VXLAN overlay networks, as defined by the network package.
+	optVxlanLocalIP = "VxlanLocalIP"
+	optVxlanPeers   = "VxlanPeers"
 )
 
 // Config describes subnet/gateway for ipam.
@@ -8,6 +8,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
 
 	"github.com/Azure/azure-container-networking/cns/imdsclient"
 	"github.com/Azure/azure-container-networking/log"
@@ -19,6 +22,11 @@ const (
 	defaultIpamPlugin          = "azure-vnet"
 	networkMode                = "com.microsoft.azure.network.mode"
 	bridgeMode                 = "bridge"
+	vxlanMode                  = "vxlan"
+
+	// Overlay subnets are not in the address spaces of the azure-vnet IPAM plugin,
+	// so overlay networks use the built-in docker IPAM plugin.
+	overlayIpamPlugin = "default"
 )
 
 // DockerClient specifies a client to connect to docker.
@@ -107,6 +115,68 @@ func (dockerClient *DockerClient) CreateNetwork(networkName string, nicInfo *imd
 		netConfig.Options[networkMode] = bridgeMode
 	}
 
+	err := dockerClient.createNetwork(netConfig)
+	if err != nil {
+		return err
+	}
+
+	if enableSnat {
+		err = platform.SetOutboundSNAT(nicInfo.Subnet)
+		if err != nil {
+			log.Printf("[Azure CNS] Error setting up SNAT outbound rule %v", err)
+		}
+	}
+
+	return nil
+}
+
+// CreateOverlayNetwork creates a VXLAN overlay network using docker network create.
+// Containers on this node get their addresses from the subnet of the local node.
+func (dockerClient *DockerClient) CreateOverlayNetwork(networkName string, overlayConfig *cns.OverlayConfiguration, options map[string]interface{}) error {
+	log.Printf("[Azure CNS] CreateOverlayNetwork")
+
+	var localSubnet string
+	var peers []string
+
+	for _, node := range overlayConfig.NodeConfig {
+		subnet := fmt.Sprintf("%s/%d", node.NodeSubnet.IPAddress, node.NodeSubnet.PrefixLength)
+		if node.NodeIP == overlayConfig.LocalNodeIP {
+			localSubnet = subnet
+		}
+
+		peers = append(peers, node.NodeIP+"="+subnet)
+	}
+
+	if localSubnet == "" {
+		return fmt.Errorf("[Azure CNS] Local node %v is not in the overlay configuration", overlayConfig.LocalNodeIP)
+	}
+
+	netConfig := &NetworkConfiguration{
+		Name:   networkName,
+		Driver: defaultNetworkPlugin,
+		IPAM: IPAM{
+			Driver: overlayIpamPlugin,
+			Config: []Config{{Subnet: localSubnet}},
+		},
+		Internal: true,
+		Options: map[string]interface{}{
+			networkMode:     vxlanMode,
+			optVxlanLocalIP: overlayConfig.LocalNodeIP,
+			optVxlanPeers:   strings.Join(peers, ","),
+		},
+	}
+
+	if options != nil {
+		if vxlanID, ok := options[OptVxlanID]; ok {
+			netConfig.Options[OptVxlanID] = fmt.Sprint(vxlanID)
+		}
+	}
+
+	return dockerClient.createNetwork(netConfig)
+}
+
+// Sends a docker network create request.
+func (dockerClient *DockerClient) createNetwork(netConfig *NetworkConfiguration) error {
 	log.Printf("[Azure CNS] Going to create network with config: %+v", netConfig)
 
 	netConfigJSON := new(bytes.Buffer)
@@ -121,7 +191,7 @@ func (dockerClient *DockerClient) CreateNetwork(networkName string, nicInfo *imd
 		netConfigJSON)
 
 	if err != nil {
-		log.Printf("[Azure CNS] Error received from http Post for docker network create %v", networkName)
+		log.Printf("[Azure CNS] Error received from http Post for docker network create %v", netConfig.Name)
 		return err
 	}
 
@@ -139,13 +209,6 @@ func (dockerClient *DockerClient) CreateNetwork(networkName string, nicInfo *imd
 			res.StatusCode, createNetworkResponse.message, ermsg)
 	}
 
-	if enableSnat {
-		err = platform.SetOutboundSNAT(nicInfo.Subnet)
-		if err != nil {
-			log.Printf("[Azure CNS] Error setting up SNAT outbound rule %v", err)
-		}
-	}
-
 	return nil
 }
 
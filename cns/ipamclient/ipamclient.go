@@ -7,6 +7,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	cnmIpam "github.com/Azure/azure-container-networking/cnm/ipam"
 	ipam "github.com/Azure/azure-container-networking/ipam"
@@ -114,7 +116,8 @@ func (ic *IpamClient) GetPoolID(asID, subnet string) (string, error) {
 }
 
 // ReserveIPAddress request an Ip address for the reservation id.
-func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (string, error) {
+// A non-zero lease duration leases the address, which is released unless renewed with RenewIPAddressLease before it expires.
+func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string, leaseDuration time.Duration) (string, error) {
 	var body bytes.Buffer
 	log.Printf("[Azure CNS] ReserveIpAddress")
 
@@ -131,6 +134,9 @@ func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (str
 		Options: make(map[string]string),
 	}
 	payload.Options[ipam.OptAddressID] = reservationID
+	if leaseDuration > 0 {
+		payload.Options[ipam.OptLeaseDuration] = strconv.Itoa(int(leaseDuration / time.Second))
+	}
 	json.NewEncoder(&body).Encode(payload)
 
 	res, err := client.Post(url, "application/json", &body)
@@ -162,6 +168,13 @@ func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (str
 	return "", err
 }
 
+// RenewIPAddressLease renews the lease on the Ip address for the reservation id.
+// The address is requested again with the same reservation id, which returns the same address.
+func (ic *IpamClient) RenewIPAddressLease(poolID string, reservationID string, leaseDuration time.Duration) (string, error) {
+	log.Printf("[Azure CNS] RenewIPAddressLease")
+	return ic.ReserveIPAddress(poolID, reservationID, leaseDuration)
+}
+
 // ReleaseIPAddress release an Ip address for the reservation id.
 func (ic *IpamClient) ReleaseIPAddress(poolID string, reservationID string) error {
 	var body bytes.Buffer
@@ -256,3 +269,47 @@ func (ic *IpamClient) GetIPAddressUtilization(poolID string) (int, int, []string
 	return 0, 0, nil, err
 
 }
+
+// GetIPAMMetrics - returns utilization metrics of the given address space, or of all address spaces if asID is empty.
+func (ic *IpamClient) GetIPAMMetrics(asID string) ([]*ipam.AddressSpaceMetrics, error) {
+	var body bytes.Buffer
+	log.Printf("[Azure CNS] GetIPAMMetrics")
+
+	client, err := getClient(ic.connectionURL)
+	if err != nil {
+		return nil, err
+	}
+	url := ic.connectionURL + cnmIpam.GetMetricsPath
+
+	payload := &cnmIpam.GetMetricsRequest{
+		AddressSpace: asID,
+	}
+
+	json.NewEncoder(&body).Encode(payload)
+
+	res, err := client.Post(url, "application/json", &body)
+	if err != nil {
+		log.Printf("[Azure CNS] HTTP Post returned error %v", err.Error())
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == 200 {
+		var metricsResp cnmIpam.GetMetricsResponse
+		err := json.NewDecoder(res.Body).Decode(&metricsResp)
+		if err != nil {
+			log.Printf("[Azure CNS] Error received while parsing GetIPAMMetrics response :%v err:%v", res.Body, err.Error())
+			return nil, err
+		}
+
+		if metricsResp.Err != "" {
+			log.Printf("[Azure CNS] GetIPAMMetrics received error response :%v", metricsResp.Err)
+			return nil, fmt.Errorf("%s", metricsResp.Err)
+		}
+
+		return metricsResp.AddressSpaces, nil
+	}
+	log.Printf("[Azure CNS] GetIPAMMetrics invalid http status code: %v", res.StatusCode)
+	return nil, fmt.Errorf("GetIPAMMetrics failed with http status code %v", res.StatusCode)
+}
@@ -9,14 +9,17 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/cnm/ipam"
+	azureIpam "github.com/Azure/azure-container-networking/ipam"
 )
 
 var mux *http.ServeMux
 var ipamQueryUrl = "localhost:42424"
 var ic *IpamClient
+var lastReserveIPRequest ipam.RequestAddressRequest
 
 // Wraps the test run with service setup and teardown.
 func TestMain(m *testing.M) {
@@ -33,6 +36,7 @@ func TestMain(m *testing.M) {
 	ipamAgent.AddHandler(ipam.RequestAddressPath, handleReserveIPQuery)
 	ipamAgent.AddHandler(ipam.ReleasePoolPath, handleReleaseIPQuery)
 	ipamAgent.AddHandler(ipam.GetPoolInfoPath, handleIPUtilizationQuery)
+	ipamAgent.AddHandler(ipam.GetMetricsPath, handleMetricsQuery)
 
 	err = ipamAgent.Start(make(chan error, 1))
 	if err != nil {
@@ -67,6 +71,8 @@ func handlePoolIDQuery(w http.ResponseWriter, r *http.Request) {
 
 // Handles queries from ReserveIPAddress.
 func handleReserveIPQuery(w http.ResponseWriter, r *http.Request) {
+	lastReserveIPRequest = ipam.RequestAddressRequest{}
+	json.NewDecoder(r.Body).Decode(&lastReserveIPRequest)
 	var reserveIPResp = "{\"Address\":\"10.0.0.2/16\"}"
 	w.Write([]byte(reserveIPResp))
 }
@@ -82,6 +88,12 @@ func handleIPUtilizationQuery(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(ipUtilizationResp))
 }
 
+// Handles queries from GetIPAMMetrics.
+func handleMetricsQuery(w http.ResponseWriter, r *http.Request) {
+	var metricsResp = "{\"AddressSpaces\":[{\"AsId\":\"local\", \"Capacity\":10, \"InUse\":3, \"Pools\":[{\"PoolId\":\"10.0.0.0/16\", \"Capacity\":10, \"InUse\":3}]}]}"
+	w.Write([]byte(metricsResp))
+}
+
 // Decodes plugin's responses to test requests.
 func decodeResponse(w *httptest.ResponseRecorder, response interface{}) error {
 	if w.Code != http.StatusOK {
@@ -145,7 +157,7 @@ func TestReserveIP(t *testing.T) {
 		return
 	}
 
-	addr1, err := ic.ReserveIPAddress(poolID, "id1")
+	addr1, err := ic.ReserveIPAddress(poolID, "id1", 0)
 	if err != nil {
 		t.Errorf("GetReserveIP failed with %v\n", err)
 		return
@@ -154,7 +166,7 @@ func TestReserveIP(t *testing.T) {
 		t.Errorf("GetReserveIP returned ivnvalid IP %s\n", addr1)
 		return
 	}
-	addr2, err := ic.ReserveIPAddress(poolID, "id1")
+	addr2, err := ic.ReserveIPAddress(poolID, "id1", 0)
 	if err != nil {
 		t.Errorf("GetReserveIP failed with %v\n", err)
 		return
@@ -166,6 +178,45 @@ func TestReserveIP(t *testing.T) {
 
 }
 
+// Tests IpamClient ReserveIPAddress and RenewIPAddressLease functions to request and renew a leased IP.
+func TestReserveIPWithLease(t *testing.T) {
+	addr1, err := ic.ReserveIPAddress("10.0.0.0/16", "id1", 90*time.Second)
+	if err != nil {
+		t.Errorf("ReserveIPAddress failed with %v\n", err)
+		return
+	}
+	if lastReserveIPRequest.Options[azureIpam.OptAddressID] != "id1" ||
+		lastReserveIPRequest.Options[azureIpam.OptLeaseDuration] != "90" {
+		t.Errorf("ReserveIPAddress sent invalid options %+v\n", lastReserveIPRequest.Options)
+		return
+	}
+
+	addr2, err := ic.RenewIPAddressLease("10.0.0.0/16", "id1", 90*time.Second)
+	if err != nil {
+		t.Errorf("RenewIPAddressLease failed with %v\n", err)
+		return
+	}
+	if addr1 != addr2 {
+		t.Errorf("RenewIPAddressLease returned invalid IP1 %s IP2 %s\n", addr1, addr2)
+		return
+	}
+	if lastReserveIPRequest.PoolID != "10.0.0.0/16" ||
+		lastReserveIPRequest.Options[azureIpam.OptAddressID] != "id1" ||
+		lastReserveIPRequest.Options[azureIpam.OptLeaseDuration] != "90" {
+		t.Errorf("RenewIPAddressLease sent invalid request %+v\n", lastReserveIPRequest)
+		return
+	}
+
+	_, err = ic.ReserveIPAddress("10.0.0.0/16", "id1", 0)
+	if err != nil {
+		t.Errorf("ReserveIPAddress failed with %v\n", err)
+		return
+	}
+	if _, ok := lastReserveIPRequest.Options[azureIpam.OptLeaseDuration]; ok {
+		t.Errorf("ReserveIPAddress without lease sent lease duration %+v\n", lastReserveIPRequest.Options)
+	}
+}
+
 // Tests IpamClient ReleaseIPAddress function to release IP associated with ID.
 func TestReleaseIP(t *testing.T) {
 	subnet := "10.0.0.0/16"
@@ -182,7 +233,7 @@ func TestReleaseIP(t *testing.T) {
 		return
 	}
 
-	addr1, err := ic.ReserveIPAddress(poolID, "id1")
+	addr1, err := ic.ReserveIPAddress(poolID, "id1", 0)
 	if err != nil {
 		t.Errorf("GetReserveIP failed with %v\n", err)
 		return
@@ -228,3 +279,15 @@ func TestIPAddressUtilization(t *testing.T) {
 
 	log.Printf("Capacity %v Available %v Unhealthy %v", capacity, available, unhealthyAddrs)
 }
+
+// Tests IpamClient GetIPAMMetrics function to retrieve address space utilization metrics.
+func TestIPAMMetrics(t *testing.T) {
+	metrics, err := ic.GetIPAMMetrics("local")
+	if err != nil {
+		t.Fatalf("GetIPAMMetrics failed with %v\n", err)
+	}
+
+	if len(metrics) != 1 || metrics[0].Capacity != 10 || metrics[0].InUse != 3 || len(metrics[0].Pools) != 1 {
+		t.Fatalf("GetIPAMMetrics returned invalid metrics %+v\n", metrics)
+	}
+}
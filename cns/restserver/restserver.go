@@ -31,8 +31,14 @@ const (
 	swiftAPIVersion = "1"
 	attach          = "Attach"
 	detach          = "Detach"
+
+	// Schema version of the persisted CNS state.
+	schemaVersion = 1
 )
 
+// Schema of the persisted CNS state.
+var stateSchema = store.NewSchema(schemaVersion)
+
 // HTTPRestService represents http listener for CNS - Container Networking Service.
 type HTTPRestService struct {
 	*cns.Service
@@ -57,6 +63,7 @@ type containerstatus struct {
 
 // httpRestServiceState contains the state we would like to persist.
 type httpRestServiceState struct {
+	SchemaVersion                    int
 	Location                         string
 	NetworkType                      string
 	OrchestratorType                 string
@@ -68,9 +75,10 @@ type httpRestServiceState struct {
 }
 
 type networkInfo struct {
-	NetworkName string
-	NicInfo     *imdsclient.InterfaceInfo
-	Options     map[string]interface{}
+	NetworkName          string
+	NicInfo              *imdsclient.InterfaceInfo
+	OverlayConfiguration *cns.OverlayConfiguration `json:",omitempty"`
+	Options              map[string]interface{}
 }
 
 // HTTPService describes the min API interface that every service should have.
@@ -322,8 +330,22 @@ func (service *HTTPRestService) createNetwork(w http.ResponseWriter, r *http.Req
 							returnCode = UnsupportedEnvironment
 						}
 					case "Overlay":
-						returnMessage = fmt.Sprintf("[Azure CNS] Error. Overlay support not yet available. %v.", err.Error())
-						returnCode = UnsupportedEnvironment
+						log.Printf("[Azure CNS] Creating overlay network with name %v.", req.NetworkName)
+
+						err = dc.CreateOverlayNetwork(req.NetworkName, &req.OverlayConfiguration, req.Options)
+						if err != nil {
+							returnMessage = fmt.Sprintf("[Azure CNS] Error. CreateOverlayNetwork failed %v.", err.Error())
+							returnCode = UnexpectedError
+							break
+						}
+
+						networkInfo := &networkInfo{
+							NetworkName:          req.NetworkName,
+							OverlayConfiguration: &req.OverlayConfiguration,
+							Options:              req.Options,
+						}
+
+						service.state.Networks[req.NetworkName] = networkInfo
 					}
 				} else {
 					returnMessage = fmt.Sprintf("[Azure CNS] Received a request to create an already existing network %v", req.NetworkName)
@@ -559,7 +581,7 @@ func (service *HTTPRestService) reserveIPAddress(w http.ResponseWriter, r *http.
 			break
 		}
 
-		addr, err = ic.ReserveIPAddress(poolID, req.ReservationID)
+		addr, err = ic.ReserveIPAddress(poolID, req.ReservationID, 0)
 		if err != nil {
 			returnMessage = fmt.Sprintf("[Azure CNS] ReserveIpAddress failed with %+v", err.Error())
 			returnCode = AddressUnavailable
@@ -912,8 +934,9 @@ func (service *HTTPRestService) saveState() error {
 		return nil
 	}
 
-	// Update time stamp.
+	// Update time stamp and schema version.
 	service.state.TimeStamp = time.Now()
+	service.state.SchemaVersion = schemaVersion
 	err := service.store.Write(storeKey, &service.state)
 	if err == nil {
 		log.Printf("[Azure CNS]  State saved successfully.\n")
@@ -935,7 +958,7 @@ func (service *HTTPRestService) restoreState() error {
 	}
 
 	// Read any persisted state.
-	err := service.store.Read(storeKey, &service.state)
+	err := stateSchema.Read(service.store, storeKey, &service.state)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
 			// Nothing to restore.
@@ -1209,6 +1232,7 @@ func (service *HTTPRestService) getNetworkContainerResponse(req cns.GetNetworkCo
 		LocalIPConfiguration:       savedReq.LocalIPConfiguration,
 		AllowHostToNCCommunication: savedReq.AllowHostToNCCommunication,
 		AllowNCToHostCommunication: savedReq.AllowNCToHostCommunication,
+		MTU:                        savedReq.MTU,
 	}
 
 	return getNetworkContainerResponse
@@ -1457,6 +1481,17 @@ func (service *HTTPRestService) restoreNetworkState() error {
 
 			log.Printf("[Azure CNS] Restore nwinfo %v", nwInfo)
 
+			// Overlay networks have no outbound SNAT rule on the node interface.
+			if nwInfo.OverlayConfiguration != nil {
+				service.restoreOverlayNetwork(nwInfo)
+				continue
+			}
+
+			// Networks without interface information have nothing to restore.
+			if nwInfo.NicInfo == nil {
+				continue
+			}
+
 			if nwInfo.Options != nil {
 				if _, ok := nwInfo.Options[dockerclient.OptDisableSnat]; ok {
 					enableSnat = false
@@ -1476,6 +1511,24 @@ func (service *HTTPRestService) restoreNetworkState() error {
 	return nil
 }
 
+// restoreOverlayNetwork recreates an overlay network that did not survive a reboot.
+// The network plugin recreates the VXLAN interface and its peers with the network.
+// Failures are not fatal, since the next request to create the network creates it again.
+func (service *HTTPRestService) restoreOverlayNetwork(nwInfo *networkInfo) {
+	dc := service.dockerClient
+
+	if err := dc.NetworkExists(nwInfo.NetworkName); err == nil {
+		return
+	}
+
+	log.Printf("[Azure CNS] Recreating overlay network %v.", nwInfo.NetworkName)
+
+	err := dc.CreateOverlayNetwork(nwInfo.NetworkName, nwInfo.OverlayConfiguration, nwInfo.Options)
+	if err != nil {
+		log.Printf("[Azure CNS] Failed to recreate overlay network %v, err:%v.", nwInfo.NetworkName, err)
+	}
+}
+
 func (service *HTTPRestService) attachNetworkContainerToNetwork(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[Azure CNS] attachNetworkContainerToNetwork")
 
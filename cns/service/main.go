@@ -117,6 +117,17 @@ var args = acn.ArgumentList{
 		Type:         "bool",
 		DefaultValue: false,
 	},
+	{
+		Name:         acn.OptStoreType,
+		Shorthand:    acn.OptStoreTypeAlias,
+		Description:  "Set the persistent store type",
+		Type:         "string",
+		DefaultValue: acn.OptStoreTypeJson,
+		ValueMap: map[string]interface{}{
+			acn.OptStoreTypeJson: 0,
+			acn.OptStoreTypeBolt: 0,
+		},
+	},
 	{
 		Name:         acn.OptVersion,
 		Shorthand:    acn.OptVersionAlias,
@@ -180,6 +191,7 @@ func main() {
 	vers := acn.GetArg(acn.OptVersion).(bool)
 	createDefaultExtNetworkType := acn.GetArg(acn.OptCreateDefaultExtNetworkType).(string)
 	telemetryEnabled := acn.GetArg(acn.OptTelemetry).(bool)
+	storeType := acn.GetArg(acn.OptStoreType).(string)
 
 	if vers {
 		printVersion()
@@ -223,7 +235,7 @@ func main() {
 	}
 
 	// Create the key value store.
-	config.Store, err = store.NewJsonFileStore(platform.CNMRuntimePath + name + ".json")
+	config.Store, err = store.NewStore(storeType, platform.CNMRuntimePath+name)
 	if err != nil {
 		log.Errorf("Failed to create store: %v\n", err)
 		return
@@ -293,7 +305,7 @@ func main() {
 		}
 
 		// Create the key value store.
-		pluginConfig.Store, err = store.NewJsonFileStore(platform.CNMRuntimePath + pluginName + ".json")
+		pluginConfig.Store, err = store.NewStore(storeType, platform.CNMRuntimePath+pluginName)
 		if err != nil {
 			log.Errorf("Failed to create store: %v\n", err)
 			return
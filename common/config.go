@@ -10,6 +10,7 @@ const (
 	OptEnvironmentAlias = "e"
 	OptEnvironmentAzure = "azure"
 	OptEnvironmentMAS   = "mas"
+	OptEnvironmentFile  = "file"
 
 	// API server URL.
 	OptAPIServerURL      = "api-url"
@@ -44,6 +45,10 @@ const (
 	OptIpamQueryInterval      = "ipam-query-interval"
 	OptIpamQueryIntervalAlias = "i"
 
+	// IPAM static configuration file.
+	OptIpamConfigFile      = "ipam-config-file"
+	OptIpamConfigFileAlias = "f"
+
 	// Don't Start CNM
 	OptStopAzureVnet      = "stop-azure-cnm"
 	OptStopAzureVnetAlias = "stopcnm"
@@ -79,4 +84,10 @@ const (
 	// Disable Telemetry
 	OptTelemetry      = "telemetry"
 	OptTelemetryAlias = "dt"
+
+	// Persistent store type.
+	OptStoreType      = "store-type"
+	OptStoreTypeAlias = "st"
+	OptStoreTypeJson  = "json"
+	OptStoreTypeBolt  = "bolt"
 )
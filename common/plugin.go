@@ -35,12 +35,13 @@ type IpamApi interface {
 
 // Plugin common configuration.
 type PluginConfig struct {
-	Version  string
-	NetApi   NetApi
-	IpamApi  IpamApi
-	Listener *Listener
-	ErrChan  chan error
-	Store    store.KeyValueStore
+	Version   string
+	NetApi    NetApi
+	IpamApi   IpamApi
+	Listener  *Listener
+	ErrChan   chan error
+	Store     store.KeyValueStore
+	StoreType string
 }
 
 // NewPlugin creates a new Plugin object.
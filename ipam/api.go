@@ -24,10 +24,49 @@ var (
 	errAddressInUse            = fmt.Errorf("Address already in use")
 	errAddressNotInUse         = fmt.Errorf("Address not in use")
 	errNoAvailableAddresses    = fmt.Errorf("No available addresses")
+	errInvalidAllocStrategy    = fmt.Errorf("Invalid address allocation strategy")
+	errPoolSettingsConflict    = fmt.Errorf("Address pool is in use with different settings")
+	errInvalidQuarantine       = fmt.Errorf("Invalid address quarantine interval")
+	errAddressExcluded         = fmt.Errorf("Address is excluded from allocation")
+	errAddressReserved         = fmt.Errorf("Address is reserved")
+	errInvalidReservationTTL   = fmt.Errorf("Invalid address reservation TTL")
+	errInvalidDetectDuplicates = fmt.Errorf("Invalid duplicate address detection setting")
+	errDuplicateAddresses      = fmt.Errorf("Available addresses are in use on the network")
+	errInvalidChildPrefix      = fmt.Errorf("Invalid child prefix")
+	errChildPrefixUnavailable  = fmt.Errorf("No available child prefix")
+	errAddressPoolHasAddresses = fmt.Errorf("Address pool has addresses in use")
+	errInvalidHighWaterMark    = fmt.Errorf("Invalid high-water mark")
+	errInvalidLeaseDuration    = fmt.Errorf("Invalid address lease duration")
+	errInvalidPoolPolicy       = fmt.Errorf("Invalid pool selection policy")
+
+	// Error returned by AddressManager clients of the IPAM daemon.
+	errExhaustionHandlerNotSupported = fmt.Errorf("Exhaustion events are reported by the IPAM daemon")
 
 	// Options used by AddressManager.
 	OptInterfaceName      = "azure.interface.name"
 	OptAddressID          = "azure.address.id"
+	OptAddressOwner       = "azure.address.owner"
 	OptAddressType        = "azure.address.type"
 	OptAddressTypeGateway = "gateway"
+	OptAllocStrategy      = "azure.address.allocstrategy"
+	OptQuarantineInterval = "azure.address.quarantineinterval"
+	OptExcludedAddresses  = "azure.address.excluded"
+	OptReservationTTL     = "azure.address.reservationttl"
+	OptDetectDuplicates   = "azure.address.detectduplicates"
+	OptChildPrefixLength  = "azure.address.childprefixlength"
+	OptHighWaterMark      = "azure.address.highwatermark"
+	OptLeaseDuration      = "azure.address.leaseduration"
+	OptPoolPolicy         = "azure.address.poolpolicy"
+
+	// Address allocation strategies.
+	AllocStrategyAny                   = "any"
+	AllocStrategyLowestFree            = "lowest"
+	AllocStrategyRoundRobin            = "roundrobin"
+	AllocStrategyLeastRecentlyReleased = "lru"
+
+	// Pool selection policies.
+	PoolPolicyPriority = "priority"
+	PoolPolicySpread   = "spread"
+	PoolPolicyFill     = "fill"
+	PoolPolicyPinned   = "pinned"
 )
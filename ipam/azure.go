@@ -12,6 +12,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
 )
 
 const (
@@ -141,6 +142,14 @@ func (s *azureSource) refresh() error {
 				continue
 			}
 
+			// IPv6 subnets use the well-known VNET DNS host IDs.
+			if ap.IsIPv6 {
+				ap.DnsServers = []net.IP{
+					platform.GenerateAddress(subnet, dnsPrimaryHostId),
+					platform.GenerateAddress(subnet, dnsSecondaryHostId),
+				}
+			}
+
 			// For each address in the subnet...
 			for _, a := range s.IPAddress {
 				// Primary addresses are reserved for the host.
@@ -160,7 +169,5 @@ func (s *azureSource) refresh() error {
 	}
 
 	// Set the local address space as active.
-	s.sink.setAddressSpace(local)
-
-	return nil
+	return s.sink.setAddressSpace(local)
 }
@@ -0,0 +1,94 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+)
+
+const dualStackInterfaceConfig = `<Interfaces>
+	<Interface MacAddress="*" IsPrimary="true">
+		<IPSubnet Prefix="10.0.0.0/16">
+			<IPAddress Address="10.0.0.4" IsPrimary="true"/>
+			<IPAddress Address="10.0.0.5" IsPrimary="false"/>
+		</IPSubnet>
+		<IPSubnet Prefix="ace:cab:deca::/64">
+			<IPAddress Address="ace:cab:deca::4" IsPrimary="true"/>
+			<IPAddress Address="ace:cab:deca::5" IsPrimary="false"/>
+		</IPSubnet>
+	</Interface>
+</Interfaces>`
+
+func TestAzureSourceDualStack(t *testing.T) {
+	defer setHostSubnets()()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, dualStackInterfaceConfig)
+	}))
+	defer server.Close()
+
+	am := &addressManager{
+		AddrSpaces: make(map[string]*addressSpace),
+	}
+
+	options := make(map[string]interface{})
+	options[common.OptIpamQueryUrl] = server.URL
+
+	source, _ := newAzureSource(options)
+	if err := source.start(am); err != nil {
+		t.Fatalf("source.start failed, err:%v", err)
+	}
+
+	if err := source.refresh(); err != nil {
+		t.Fatalf("source.refresh failed, err:%v", err)
+	}
+
+	// Request an address from each address family.
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", nil, false)
+	if err != nil || poolId != "10.0.0.0/16" {
+		t.Fatalf("RequestPool IPv4 failed, poolId:%v err:%v", poolId, err)
+	}
+
+	poolIdV6, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", nil, true)
+	if err != nil || poolIdV6 != "ace:cab:deca::/64" {
+		t.Fatalf("RequestPool IPv6 failed, poolId:%v err:%v", poolIdV6, err)
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolIdV6, "", nil)
+	if err != nil || address != "ace:cab:deca::5/64" {
+		t.Fatalf("RequestAddress IPv6 failed, address:%v err:%v", address, err)
+	}
+
+	// IPv6 pools use the well-known VNET gateway and DNS host IDs.
+	info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, poolIdV6)
+	if err != nil {
+		t.Fatalf("GetPoolInfo IPv6 failed, err:%v", err)
+	}
+
+	if !info.IsIPv6 || !info.Gateway.Equal(net.ParseIP("ace:cab:deca::1")) {
+		t.Fatalf("GetPoolInfo IPv6 returned incorrect info:%+v", info)
+	}
+
+	if len(info.DnsServers) != 2 ||
+		!info.DnsServers[0].Equal(net.ParseIP("ace:cab:deca::2")) ||
+		!info.DnsServers[1].Equal(net.ParseIP("ace:cab:deca::3")) {
+		t.Fatalf("GetPoolInfo IPv6 returned incorrect DnsServers:%v", info.DnsServers)
+	}
+
+	// IPv4 pools keep using the DNS host proxy.
+	info, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, poolId)
+	if err != nil {
+		t.Fatalf("GetPoolInfo IPv4 failed, err:%v", err)
+	}
+
+	if len(info.DnsServers) != 1 || !info.DnsServers[0].Equal(dnsHostProxyAddress) {
+		t.Fatalf("GetPoolInfo IPv4 returned incorrect DnsServers:%v", info.DnsServers)
+	}
+}
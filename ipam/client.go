@@ -0,0 +1,255 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Base URL of requests sent over the daemon socket. The host part is not used.
+	clientBaseURL = "http://unix"
+)
+
+// AddressManagerClient forwards AddressManager calls to an IPAM daemon over a unix socket.
+// The address manager state, store and configuration source are owned by the daemon.
+type addressManagerClient struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+// NewAddressManagerClient creates a new AddressManager that forwards calls to the IPAM daemon
+// listening on the given unix socket. It fails if the daemon is not reachable.
+func NewAddressManagerClient(socketPath string) (AddressManager, error) {
+	// Check that the daemon is running.
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	client := &addressManagerClient{
+		socketPath: socketPath,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+
+	return client, nil
+}
+
+// Sends a request to the IPAM daemon and decodes its response.
+func (client *addressManagerClient) call(path string, request interface{}, response interface{}) error {
+	var body bytes.Buffer
+
+	err := json.NewEncoder(&body).Encode(request)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.httpClient.Post(clientBaseURL+path, "application/json", &body)
+	if err != nil {
+		log.Printf("[ipam] Failed to send request to IPAM daemon at %v, err:%v.", client.socketPath, err)
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("IPAM daemon returned HTTP status %v", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(response)
+}
+
+// Returns the error carried in a response.
+// Errors with a code are returned as the address manager's own errors, so that callers can compare them.
+func responseError(errMsg string, errCode int) error {
+	if errCode > 0 && errCode <= len(responseErrors) {
+		return responseErrors[errCode-1]
+	}
+
+	if errMsg == "" {
+		return nil
+	}
+
+	return fmt.Errorf("%s", errMsg)
+}
+
+// Initialize is a no-op, since the daemon owns the address manager state.
+func (client *addressManagerClient) Initialize(config *common.PluginConfig, options map[string]interface{}) error {
+	return nil
+}
+
+// Uninitialize is a no-op, since the daemon owns the address manager state.
+func (client *addressManagerClient) Uninitialize() {
+}
+
+// StartSource is a no-op, since the daemon is configured with its own source options.
+func (client *addressManagerClient) StartSource(options map[string]interface{}) error {
+	return nil
+}
+
+// StopSource is a no-op, since the daemon owns the configuration source.
+func (client *addressManagerClient) StopSource() {
+}
+
+// GetDefaultAddressSpaces returns the default local and global address space IDs.
+func (client *addressManagerClient) GetDefaultAddressSpaces() (string, string) {
+	var resp getDefaultAddressSpacesResponse
+
+	err := client.call(GetDefaultAddressSpacesPath, &getDefaultAddressSpacesRequest{}, &resp)
+	if err != nil {
+		return "", ""
+	}
+
+	return resp.LocalDefaultAddressSpace, resp.GlobalDefaultAddressSpace
+}
+
+// RequestPool reserves an address pool.
+func (client *addressManagerClient) RequestPool(asId, poolId, subPoolId string, options map[string]string, v6 bool) (string, string, error) {
+	var resp requestPoolResponse
+
+	req := requestPoolRequest{
+		AsId:      asId,
+		PoolId:    poolId,
+		SubPoolId: subPoolId,
+		Options:   options,
+		V6:        v6,
+	}
+
+	err := client.call(RequestPoolPath, &req, &resp)
+	if err != nil {
+		return "", "", err
+	}
+
+	return resp.PoolId, resp.Subnet, responseError(resp.Err, resp.ErrCode)
+}
+
+// ReleasePool releases a previously reserved address pool.
+func (client *addressManagerClient) ReleasePool(asId string, poolId string) error {
+	var resp releasePoolResponse
+
+	req := releasePoolRequest{
+		AsId:   asId,
+		PoolId: poolId,
+	}
+
+	err := client.call(ReleasePoolPath, &req, &resp)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp.Err, resp.ErrCode)
+}
+
+// GetPoolInfo returns information about the given address pool.
+func (client *addressManagerClient) GetPoolInfo(asId string, poolId string) (*AddressPoolInfo, error) {
+	var resp getPoolInfoResponse
+
+	req := getPoolInfoRequest{
+		AsId:   asId,
+		PoolId: poolId,
+	}
+
+	err := client.call(GetPoolInfoPath, &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = responseError(resp.Err, resp.ErrCode); err != nil {
+		return nil, err
+	}
+
+	return resp.Info, nil
+}
+
+// RequestAddress reserves a new address from the address pool.
+func (client *addressManagerClient) RequestAddress(asId, poolId, address string, options map[string]string) (string, error) {
+	var resp requestAddressResponse
+
+	req := requestAddressRequest{
+		AsId:    asId,
+		PoolId:  poolId,
+		Address: address,
+		Options: options,
+	}
+
+	err := client.call(RequestAddressPath, &req, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Address, responseError(resp.Err, resp.ErrCode)
+}
+
+// ReleaseAddress releases a previously reserved address.
+func (client *addressManagerClient) ReleaseAddress(asId string, poolId string, address string, options map[string]string) error {
+	var resp releaseAddressResponse
+
+	req := releaseAddressRequest{
+		AsId:    asId,
+		PoolId:  poolId,
+		Address: address,
+		Options: options,
+	}
+
+	err := client.call(ReleaseAddressPath, &req, &resp)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp.Err, resp.ErrCode)
+}
+
+// ReleaseStaleAddresses releases addresses whose IDs are not in the set of live IDs.
+func (client *addressManagerClient) ReleaseStaleAddresses(asId string, liveIds []string) ([]*ReclaimedAddressInfo, error) {
+	var resp releaseStaleAddressesResponse
+
+	req := releaseStaleAddressesRequest{
+		AsId:    asId,
+		LiveIds: liveIds,
+	}
+
+	err := client.call(ReleaseStaleAddressesPath, &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Reclaimed, responseError(resp.Err, resp.ErrCode)
+}
+
+// GetMetrics returns utilization metrics of the given address space, or of all address spaces.
+func (client *addressManagerClient) GetMetrics(asId string) ([]*AddressSpaceMetrics, error) {
+	var resp getMetricsResponse
+
+	req := getMetricsRequest{
+		AsId: asId,
+	}
+
+	err := client.call(GetMetricsPath, &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Metrics, responseError(resp.Err, resp.ErrCode)
+}
+
+// SetExhaustionHandler is not supported, since exhaustion events are raised and reported by the daemon.
+func (client *addressManagerClient) SetExhaustionHandler(handler func(*ExhaustionEvent)) error {
+	return errExhaustionHandlerNotSupported
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Time to wait for replies to a duplicate address probe.
+	dadProbeTimeout = time.Second
+
+	// Time a duplicate address is kept out of allocation before it is probed again.
+	dadRetryInterval = 5 * time.Minute
+
+	// Maximum number of addresses probed for a single request.
+	dadMaxCandidates = 4
+)
+
+// Probes the network attached to an interface for another host using the given address.
+// Overridden by tests.
+var probeDuplicateAddress = probeAddress
+
+// Returns whether an address record was recently found in use by another host.
+func (ar *addressRecord) isDuplicate(now time.Time) bool {
+	return now.Before(ar.DuplicateUntil)
+}
+
+// Returns whether new addresses of a pool are probed for duplicates before they are handed out.
+func (ap *addressPool) detectsDuplicates() bool {
+	return ap.DetectDuplicates && ap.IfName != ""
+}
+
+// Returns whether an address request is served with a new address, rather than with
+// a specific address, the gateway address or the address already assigned to its ID.
+func (ap *addressPool) isNewAddressRequest(address string, options map[string]string) bool {
+	id := options[OptAddressID]
+	return address == "" && options[OptAddressType] != OptAddressTypeGateway && (id == "" || ap.addrsByID[id] == nil)
+}
+
+// Returns an available address of a pool that is not used by another host on the network.
+// Probes wait for replies from the network, so they run without the address manager lock, which is held
+// by the caller. Candidates are marked tentative while they are probed, so that concurrent requests skip them.
+// Addresses found in use are marked unhealthy. The returned address is then requested as a specific address.
+func (am *addressManager) probeAvailableAddress(as *addressSpace, ap *addressPool) (string, error) {
+	for i := 0; i < dadMaxCandidates; i++ {
+		ar := ap.getAvailableAddress()
+		if ar == nil {
+			return "", errNoAvailableAddresses
+		}
+
+		ar.tentative = true
+		am.Unlock()
+		duplicate, err := probeDuplicateAddress(ap.IfName, ar.Addr, ap.IsIPv6)
+		am.Lock()
+		ar.tentative = false
+
+		// The pool or the address may have changed while the lock was released.
+		if am.AddrSpaces[as.Id] != as || as.Pools[ap.Id] != ap {
+			return "", errAddressPoolNotFound
+		}
+
+		if ap.Addresses[ar.Addr.String()] != ar || !ar.isAvailable(time.Now()) {
+			continue
+		}
+
+		// Do not block allocations if the probe itself fails.
+		if err != nil {
+			log.Printf("[ipam] Failed to probe address %v on %v, err:%v.", ar.Addr, ap.IfName, err)
+		}
+
+		if err != nil || !duplicate {
+			ar.DuplicateUntil = time.Time{}
+			ap.LastAddr = ar.Addr
+			return ar.Addr.String(), nil
+		}
+
+		log.Printf("[ipam] Address %v is already in use on the network, marking it unhealthy.", ar.Addr)
+		ar.unhealthy = true
+		ar.DuplicateUntil = time.Now().Add(dadRetryInterval)
+	}
+
+	log.Printf("[ipam] Found %v addresses in use on the network in pool %v.", dadMaxCandidates, ap.Id)
+
+	return "", errDuplicateAddresses
+}
@@ -0,0 +1,242 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ARP packet format for IPv4 over Ethernet.
+	arpPacketLength = 28
+	arpOpRequest    = 1
+
+	// ICMPv6 neighbor discovery message types.
+	icmpv6NeighborSolicitation  = 135
+	icmpv6NeighborAdvertisement = 136
+
+	// Lengths of the IPv6 header, and of neighbor discovery messages without options.
+	ipv6HeaderLength = 40
+	ndMessageLength  = 24
+
+	// Interval at which blocking reads wake up to check the probe deadline.
+	dadReadTimeout = 100 * time.Millisecond
+)
+
+// Probes the network attached to an interface for another host using the given address.
+func probeAddress(ifName string, addr net.IP, v6 bool) (bool, error) {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return false, err
+	}
+
+	if v6 {
+		return probeNeighborSolicitation(iface, addr)
+	}
+
+	return probeArp(iface, addr)
+}
+
+// Sends an RFC 5227 ARP probe and waits for a reply from another host.
+func probeArp(iface *net.Interface, addr net.IP) (bool, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return false, err
+	}
+	defer unix.Close(fd)
+
+	sa := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  iface.Index,
+	}
+
+	if err = unix.Bind(fd, sa); err != nil {
+		return false, err
+	}
+
+	if err = setReadTimeout(fd); err != nil {
+		return false, err
+	}
+
+	// Build the probe with an all-zero sender address so that neighbor caches are not updated.
+	pkt := make([]byte, arpPacketLength)
+	binary.BigEndian.PutUint16(pkt[0:2], 1)
+	binary.BigEndian.PutUint16(pkt[2:4], unix.ETH_P_IP)
+	pkt[4] = 6
+	pkt[5] = 4
+	binary.BigEndian.PutUint16(pkt[6:8], arpOpRequest)
+	copy(pkt[8:14], iface.HardwareAddr)
+	copy(pkt[24:28], addr.To4())
+
+	dst := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  iface.Index,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	if err = unix.Sendto(fd, pkt, 0, dst); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, 1500)
+	deadline := time.Now().Add(dadProbeTimeout)
+
+	for time.Now().Before(deadline) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			return false, err
+		}
+
+		if n < arpPacketLength {
+			continue
+		}
+
+		// Any ARP packet sent by another host from the probed address is a conflict.
+		sha := buf[8:14]
+		spa := net.IP(buf[14:18])
+		if spa.Equal(addr) && !bytes.Equal(sha, iface.HardwareAddr) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Sends an RFC 4862 duplicate address detection neighbor solicitation and waits for a neighbor advertisement
+// for the address. The solicitation is sent from the unspecified address without a source link-layer address
+// option, so that it does not update the neighbor caches of other hosts. IP sockets do not send from the
+// unspecified address, so the packet is built and sent on a packet socket.
+func probeNeighborSolicitation(iface *net.Interface, addr net.IP) (bool, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_IPV6)))
+	if err != nil {
+		return false, err
+	}
+	defer unix.Close(fd)
+
+	sa := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_IPV6),
+		Ifindex:  iface.Index,
+	}
+
+	if err = unix.Bind(fd, sa); err != nil {
+		return false, err
+	}
+
+	if err = setReadTimeout(fd); err != nil {
+		return false, err
+	}
+
+	// Send to the solicited-node multicast address of the target.
+	target := addr.To16()
+	dstIP := net.ParseIP("ff02::1:ff00:0")
+	copy(dstIP[13:], target[13:])
+
+	// Neighbor discovery messages must have a hop limit of 255.
+	pkt := make([]byte, ipv6HeaderLength+ndMessageLength)
+	pkt[0] = 6 << 4
+	binary.BigEndian.PutUint16(pkt[4:6], ndMessageLength)
+	pkt[6] = unix.IPPROTO_ICMPV6
+	pkt[7] = 255
+	copy(pkt[8:24], net.IPv6unspecified)
+	copy(pkt[24:40], dstIP)
+
+	msg := pkt[ipv6HeaderLength:]
+	msg[0] = icmpv6NeighborSolicitation
+	copy(msg[8:24], target)
+	binary.BigEndian.PutUint16(msg[2:4], getICMPv6Checksum(net.IPv6unspecified, dstIP, msg))
+
+	// Send to the Ethernet multicast address mapped from the solicited-node multicast address.
+	dst := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_IPV6),
+		Ifindex:  iface.Index,
+		Halen:    6,
+		Addr:     [8]byte{0x33, 0x33, dstIP[12], dstIP[13], dstIP[14], dstIP[15]},
+	}
+
+	if err = unix.Sendto(fd, pkt, 0, dst); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, 1500)
+	deadline := time.Now().Add(dadProbeTimeout)
+
+	for time.Now().Before(deadline) {
+		n, from, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			return false, err
+		}
+
+		// Skip packets sent by this host, including the solicitation.
+		if sa, ok := from.(*unix.SockaddrLinklayer); ok && sa.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		if n < ipv6HeaderLength+ndMessageLength || buf[6] != unix.IPPROTO_ICMPV6 {
+			continue
+		}
+
+		msg := buf[ipv6HeaderLength:n]
+		if msg[0] == icmpv6NeighborAdvertisement && net.IP(msg[8:24]).Equal(addr) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the checksum of an ICMPv6 message, which covers the IPv6 pseudo-header.
+func getICMPv6Checksum(src net.IP, dst net.IP, msg []byte) uint16 {
+	var sum uint32
+
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+
+	// The pseudo-header has the addresses, the message length and the next header.
+	var lengthAndNext [8]byte
+	binary.BigEndian.PutUint32(lengthAndNext[0:4], uint32(len(msg)))
+	lengthAndNext[7] = unix.IPPROTO_ICMPV6
+
+	add(src.To16())
+	add(dst.To16())
+	add(lengthAndNext[:])
+	add(msg)
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+
+	return ^uint16(sum)
+}
+
+// Sets a short receive timeout so that reads do not block past the probe deadline.
+func setReadTimeout(fd int) error {
+	tv := unix.NsecToTimeval(int64(dadReadTimeout))
+	return unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+}
+
+// Converts a 16-bit value from host to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
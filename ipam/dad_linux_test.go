@@ -0,0 +1,32 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"unsafe"
+)
+
+// Tests that probes are built in network byte order with valid ICMPv6 checksums.
+func TestProbeEncoding(t *testing.T) {
+	v := htons(0x86dd)
+	b := (*[2]byte)(unsafe.Pointer(&v))
+	if b[0] != 0x86 || b[1] != 0xdd {
+		t.Errorf("htons returned bytes %x, expected 86dd.", *b)
+	}
+
+	// A message including its checksum sums to zero.
+	src := net.IPv6unspecified
+	dst := net.ParseIP("ff02::1:ff00:5")
+	msg := make([]byte, ndMessageLength)
+	msg[0] = icmpv6NeighborSolicitation
+	copy(msg[8:24], net.ParseIP("fd00::5"))
+
+	binary.BigEndian.PutUint16(msg[2:4], getICMPv6Checksum(src, dst, msg))
+	if sum := getICMPv6Checksum(src, dst, msg); sum != 0 {
+		t.Errorf("ICMPv6 message with checksum sums to %x, expected 0.", sum)
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+)
+
+// Probes the network attached to an interface for another host using the given address.
+// Duplicate address detection is left to the host networking service on Windows.
+func probeAddress(ifName string, addr net.IP, v6 bool) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Returns whether an address pool is a child prefix delegated from a parent pool.
+func (ap *addressPool) isChild() bool {
+	return ap.ParentId != ""
+}
+
+// Returns whether an address is delegated to a child pool.
+func (ap *addressPool) isDelegated(addr net.IP) bool {
+	for _, r := range ap.DelegatedRanges {
+		if r.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether a prefix overlaps any prefix already delegated from the pool.
+func (ap *addressPool) overlapsDelegatedRange(prefix *net.IPNet) bool {
+	for _, r := range ap.DelegatedRanges {
+		if r.Contains(prefix.IP) || prefix.Contains(r.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether a prefix can be delegated from the pool.
+// A prefix is delegatable if it does not overlap other delegations and none of its addresses are allocated.
+func (ap *addressPool) canDelegate(prefix *net.IPNet) bool {
+	if ap.overlapsDelegatedRange(prefix) {
+		return false
+	}
+
+	now := time.Now()
+	free := 0
+
+	for _, ar := range ap.Addresses {
+		if !prefix.Contains(ar.Addr) {
+			continue
+		}
+
+		if ar.InUse || ar.isReserved(now) {
+			return false
+		}
+
+		if !ap.isExcluded(ar.Addr) {
+			free++
+		}
+	}
+
+	return free > 0
+}
+
+// Returns the lowest delegatable prefix of the given length in the pool.
+func (ap *addressPool) findChildPrefix(ones int) *net.IPNet {
+	_, bits := ap.Subnet.Mask.Size()
+	mask := net.CIDRMask(ones, bits)
+
+	// Candidate prefixes are derived from existing address records,
+	// so that sparse pools are searched without enumerating the whole subnet.
+	var candidates []*net.IPNet
+	seen := make(map[string]bool)
+
+	for _, ar := range ap.Addresses {
+		prefix := &net.IPNet{IP: ar.Addr.Mask(mask), Mask: mask}
+		if seen[prefix.String()] {
+			continue
+		}
+		seen[prefix.String()] = true
+		candidates = append(candidates, prefix)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return compareAddresses(candidates[i].IP, candidates[j].IP) < 0
+	})
+
+	for _, prefix := range candidates {
+		if ap.canDelegate(prefix) {
+			return prefix
+		}
+	}
+
+	return nil
+}
+
+// Delegates a child prefix from a parent pool and returns the child pool.
+// The child prefix is either given explicitly in subPoolId or carved out by prefix length.
+// The caller takes the reference on the returned child pool.
+func (as *addressSpace) requestChildPool(parent *addressPool, subPoolId string, options map[string]string) (*addressPool, error) {
+	var prefix *net.IPNet
+	var err error
+
+	if parent.isChild() {
+		return nil, errInvalidChildPrefix
+	}
+
+	parentOnes, bits := parent.Subnet.Mask.Size()
+
+	if subPoolId != "" {
+		// Delegate the specific prefix requested.
+		_, prefix, err = net.ParseCIDR(subPoolId)
+		if err != nil {
+			return nil, errInvalidChildPrefix
+		}
+
+		// Child pools can be shared when specifically requested.
+		if child := as.Pools[prefix.String()]; child != nil {
+			if child.ParentId != parent.Id {
+				return nil, errInvalidChildPrefix
+			}
+			return child, nil
+		}
+
+		ones, childBits := prefix.Mask.Size()
+		if childBits != bits || ones <= parentOnes || !parent.Subnet.Contains(prefix.IP) {
+			return nil, errInvalidChildPrefix
+		}
+
+		if !parent.canDelegate(prefix) {
+			return nil, errChildPrefixUnavailable
+		}
+	} else {
+		// Carve out the lowest available prefix of the requested length.
+		ones, err := strconv.Atoi(options[OptChildPrefixLength])
+		if err != nil || ones <= parentOnes || ones > bits {
+			log.Printf("[ipam] Invalid child prefix length %v.", options[OptChildPrefixLength])
+			return nil, errInvalidChildPrefix
+		}
+
+		prefix = parent.findChildPrefix(ones)
+		if prefix == nil {
+			return nil, errChildPrefixUnavailable
+		}
+	}
+
+	// Create the child pool with copies of the parent's address records in the prefix.
+	child, err := as.newAddressPool(parent.IfName, parent.Priority, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	child.ParentId = parent.Id
+	child.Gateway = parent.Gateway
+	child.DnsServers = parent.DnsServers
+	child.ExcludedRanges = parent.ExcludedRanges
+	child.SourceExcludedRanges = parent.SourceExcludedRanges
+
+	for _, ar := range parent.Addresses {
+		if prefix.Contains(ar.Addr) {
+			addr := ar.Addr
+			child.newAddressRecord(&addr)
+		}
+	}
+
+	parent.DelegatedRanges = append(parent.DelegatedRanges, *prefix)
+	parent.RefCount++
+
+	log.Printf("[ipam] Delegated child pool %v from pool %v.", child.Id, parent.Id)
+
+	return child, nil
+}
+
+// Releases a child pool, returning its prefix to the parent pool once it is no longer referenced.
+func (as *addressSpace) releaseChildPool(child *addressPool) error {
+	if child.RefCount == 1 {
+		for _, ar := range child.Addresses {
+			if ar.InUse {
+				return errAddressPoolHasAddresses
+			}
+		}
+	}
+
+	child.RefCount--
+	if child.isInUse() {
+		return nil
+	}
+
+	as.deleteChildPool(child)
+
+	return nil
+}
+
+// Deletes a child pool and returns its prefix to the parent pool.
+func (as *addressSpace) deleteChildPool(child *addressPool) {
+	delete(as.Pools, child.Id)
+	child.as = nil
+
+	parent := as.Pools[child.ParentId]
+	if parent == nil {
+		return
+	}
+
+	for i, r := range parent.DelegatedRanges {
+		if r.String() == child.Id {
+			parent.DelegatedRanges = append(parent.DelegatedRanges[:i], parent.DelegatedRanges[i+1:]...)
+			break
+		}
+	}
+
+	if parent.RefCount > 0 {
+		parent.RefCount--
+	}
+
+	log.Printf("[ipam] Returned child pool %v to pool %v.", child.Id, parent.Id)
+}
@@ -0,0 +1,350 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/watch"
+)
+
+const (
+	defaultLinuxConfigFilePath   = "/etc/kubernetes/azure-ipam.json"
+	defaultWindowsConfigFilePath = `c:\k\azure-ipam.json`
+
+	// Maximum number of host bits in an address range expanded to address records.
+	maxAddressRangeHostBits = 16
+)
+
+// Static file IPAM configuration source.
+// The mutex guards the sink and watcher, which are shared with the watcher goroutine.
+type fileSource struct {
+	name        string
+	sink        addressConfigSink
+	filePath    string
+	modTime     time.Time
+	size        int64
+	stopWatcher func()
+	sync.Mutex
+}
+
+// Static IPAM configuration file format.
+type StaticIpamConfig struct {
+	AddressSpaces []StaticAddressSpace `json:"addressSpaces"`
+}
+
+type StaticAddressSpace struct {
+	Id    string              `json:"id"`
+	Scope string              `json:"scope,omitempty"`
+	Pools []StaticAddressPool `json:"pools"`
+}
+
+type StaticAddressPool struct {
+	IfName         string   `json:"interface,omitempty"`
+	Priority       int      `json:"priority,omitempty"`
+	Subnet         string   `json:"subnet"`
+	Gateway        string   `json:"gateway,omitempty"`
+	DnsServers     []string `json:"dnsServers,omitempty"`
+	Addresses      []string `json:"addresses,omitempty"`
+	ExcludedRanges []string `json:"excludedRanges,omitempty"`
+}
+
+// Creates the static file source.
+func newFileSource(options map[string]interface{}) (*fileSource, error) {
+	filePath, _ := options[common.OptIpamConfigFile].(string)
+	if filePath == "" {
+		if runtime.GOOS == windows {
+			filePath = defaultWindowsConfigFilePath
+		} else {
+			filePath = defaultLinuxConfigFilePath
+		}
+	}
+
+	return &fileSource{
+		name:     "File",
+		filePath: filePath,
+	}, nil
+}
+
+// Starts the static file source.
+func (source *fileSource) start(sink addressConfigSink) error {
+	source.Lock()
+	defer source.Unlock()
+
+	source.sink = sink
+
+	// Reload the file as soon as it changes, instead of waiting for the next refresh.
+	// If the file cannot be watched, changes are still picked up on refresh.
+	stopWatcher, err := watch.File(source.filePath, source.onFileChanged)
+	if err != nil {
+		log.Printf("[ipam] Failed to watch %v, err:%v.", source.filePath, err)
+	} else {
+		source.stopWatcher = stopWatcher
+	}
+
+	return nil
+}
+
+// Stops the static file source.
+func (source *fileSource) stop() {
+	source.Lock()
+	defer source.Unlock()
+
+	if source.stopWatcher != nil {
+		source.stopWatcher()
+		source.stopWatcher = nil
+	}
+
+	source.sink = nil
+}
+
+// Handles change notifications for the configuration file.
+func (source *fileSource) onFileChanged() {
+	sink := source.getSink()
+	if sink == nil {
+		return
+	}
+
+	log.Printf("[ipam] Detected change in %v.", source.filePath)
+
+	err := sink.applyUpdate(func() error {
+		// Writes in quick succession can leave the modification time and size unchanged.
+		source.modTime = time.Time{}
+		return source.load(sink)
+	})
+	if err != nil {
+		log.Printf("[ipam] Failed to reload %v, keeping the last good configuration, err:%v.", source.filePath, err)
+	}
+}
+
+// Returns the sink of a started source, or nil if the source is stopped.
+func (source *fileSource) getSink() addressConfigSink {
+	source.Lock()
+	defer source.Unlock()
+
+	return source.sink
+}
+
+// Refreshes configuration.
+func (source *fileSource) refresh() error {
+	sink := source.getSink()
+	if sink == nil {
+		return nil
+	}
+
+	return source.load(sink)
+}
+
+// Loads the configuration file into the given sink.
+// Callers hold the lock of the sink, which also guards the modification time and size of the file.
+func (source *fileSource) load(sink addressConfigSink) error {
+	// Reload only if the file changed since it was last loaded.
+	info, err := os.Stat(source.filePath)
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(source.modTime) && info.Size() == source.size {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(source.filePath)
+	if err != nil {
+		return err
+	}
+
+	config := &StaticIpamConfig{}
+	if err = json.Unmarshal(data, config); err != nil {
+		return err
+	}
+
+	// Build and validate all address spaces before applying any,
+	// so that an invalid file leaves the current state intact.
+	var addressSpaces []*addressSpace
+	ids := make(map[string]bool)
+	for _, sas := range config.AddressSpaces {
+		if ids[sas.Id] {
+			return fmt.Errorf("Duplicate address space %v", sas.Id)
+		}
+		ids[sas.Id] = true
+
+		as, err := newStaticAddressSpace(sink, &sas)
+		if err != nil {
+			return err
+		}
+		addressSpaces = append(addressSpaces, as)
+	}
+
+	if err = sink.setAddressSpaces(addressSpaces); err != nil {
+		return err
+	}
+
+	log.Printf("[ipam] Address spaces successfully populated from config file %v.", source.filePath)
+	source.modTime = info.ModTime()
+	source.size = info.Size()
+
+	return nil
+}
+
+// Creates an address space from its static configuration.
+func newStaticAddressSpace(sink addressConfigSink, sas *StaticAddressSpace) (*addressSpace, error) {
+	var scope int
+
+	switch sas.Scope {
+	case "", "local":
+		scope = LocalScope
+	case "global":
+		scope = GlobalScope
+	default:
+		return nil, fmt.Errorf("Invalid scope %v for address space %v", sas.Scope, sas.Id)
+	}
+
+	if sas.Id == "" {
+		return nil, errInvalidAddressSpace
+	}
+
+	as, err := sink.newAddressSpace(sas.Id, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sap := range sas.Pools {
+		if err = populateStaticAddressPool(as, &sap); err != nil {
+			return nil, err
+		}
+	}
+
+	return as, nil
+}
+
+// Creates an address pool and its address records from its static configuration.
+func populateStaticAddressPool(as *addressSpace, sap *StaticAddressPool) error {
+	_, subnet, err := net.ParseCIDR(sap.Subnet)
+	if err != nil {
+		return err
+	}
+
+	ap, err := as.newAddressPool(sap.IfName, sap.Priority, subnet)
+	if err != nil {
+		return fmt.Errorf("Failed to create pool %v: %v", sap.Subnet, err)
+	}
+
+	if sap.Gateway != "" {
+		ap.Gateway = net.ParseIP(sap.Gateway)
+		if ap.Gateway == nil || !subnet.Contains(ap.Gateway) {
+			return fmt.Errorf("Invalid gateway %v for pool %v", sap.Gateway, sap.Subnet)
+		}
+	}
+
+	for _, s := range sap.DnsServers {
+		dnsServer := net.ParseIP(s)
+		if dnsServer == nil {
+			return fmt.Errorf("Invalid DNS server %v for pool %v", s, sap.Subnet)
+		}
+		ap.DnsServers = append(ap.DnsServers, dnsServer)
+	}
+
+	for _, s := range sap.ExcludedRanges {
+		r, err := parseAddressRange(s)
+		if err != nil {
+			return err
+		}
+		ap.SourceExcludedRanges = append(ap.SourceExcludedRanges, *r)
+	}
+
+	// Collect the addresses to add, defaulting to the entire subnet.
+	var ranges []*net.IPNet
+	if len(sap.Addresses) == 0 {
+		ranges = append(ranges, subnet)
+	} else {
+		for _, s := range sap.Addresses {
+			r, err := parseAddressRange(s)
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	for _, r := range ranges {
+		ones, bits := r.Mask.Size()
+		if bits-ones > maxAddressRangeHostBits {
+			return fmt.Errorf("Address range %v is too large", r)
+		}
+
+		for addr := r.IP.Mask(r.Mask); r.Contains(addr); addr = nextAddress(addr) {
+			if !isAssignableAddress(ap, addr) {
+				continue
+			}
+
+			address := addr
+			if _, err = ap.newAddressRecord(&address); err != nil && err != errAddressExists {
+				return fmt.Errorf("Failed to create address %v: %v", address, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the address following the given address.
+func nextAddress(addr net.IP) net.IP {
+	next := make(net.IP, len(addr))
+	copy(next, addr)
+
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	// Stop iteration on wraparound.
+	if next.Equal(net.IPv4zero) || next.Equal(net.IPv6zero) {
+		return nil
+	}
+
+	return next
+}
+
+// Returns whether an address can be assigned to a container.
+func isAssignableAddress(ap *addressPool, addr net.IP) bool {
+	// Skip the gateway and DNS servers.
+	if addr.Equal(ap.Gateway) {
+		return false
+	}
+
+	for _, dnsServer := range ap.DnsServers {
+		if addr.Equal(dnsServer) {
+			return false
+		}
+	}
+
+	// Skip the network and broadcast addresses of IPv4 subnets.
+	if !ap.IsIPv6 {
+		ones, bits := ap.Subnet.Mask.Size()
+		if bits-ones >= 2 {
+			network := ap.Subnet.IP.Mask(ap.Subnet.Mask)
+			broadcast := make(net.IP, len(network))
+			for i := range network {
+				broadcast[i] = network[i] | ^ap.Subnet.Mask[i]
+			}
+
+			if addr.Equal(network) || addr.Equal(broadcast) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,239 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+)
+
+func TestNewFileSource(t *testing.T) {
+	options := make(map[string]interface{})
+	options[common.OptIpamConfigFile] = "testfiles/staticIpamConfig.json"
+
+	source, _ := newFileSource(options)
+	if source.filePath != "testfiles/staticIpamConfig.json" {
+		t.Fatalf("file path set incorrectly")
+	}
+	if source.name != "File" {
+		t.Fatalf("file source name incorrect")
+	}
+}
+
+func TestFileSourceRefresh(t *testing.T) {
+	defer setHostSubnets()()
+
+	am := &addressManager{
+		AddrSpaces: make(map[string]*addressSpace),
+	}
+
+	options := make(map[string]interface{})
+	options[common.OptIpamConfigFile] = "testfiles/staticIpamConfig.json"
+
+	source, _ := newFileSource(options)
+	if err := source.start(am); err != nil {
+		t.Fatalf("failed to start file source: %v", err)
+	}
+
+	if err := source.refresh(); err != nil {
+		t.Fatalf("failed to refresh file source: %v", err)
+	}
+
+	as, err := am.getAddressSpace(LocalDefaultAddressSpaceId)
+	if err != nil {
+		t.Fatalf("local address space not found: %v", err)
+	}
+
+	// The first pool skips the network, broadcast, gateway and DNS server addresses.
+	ap, err := as.getAddressPool("10.1.0.0/28")
+	if err != nil {
+		t.Fatalf("pool 10.1.0.0/28 not found: %v", err)
+	}
+
+	expected := []string{
+		"10.1.0.1", "10.1.0.10", "10.1.0.11", "10.1.0.12", "10.1.0.13", "10.1.0.3",
+		"10.1.0.4", "10.1.0.5", "10.1.0.6", "10.1.0.7", "10.1.0.8", "10.1.0.9",
+	}
+	if actual := sortedAddresses(ap); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("pool addresses did not match. expected: %v, actual: %v", expected, actual)
+	}
+
+	// Excluded addresses do not count towards capacity.
+	info := ap.getInfo()
+	if info.Capacity != 7 || info.Available != 7 || len(info.ExcludedRanges) != 2 {
+		t.Fatalf("pool capacity did not account for excluded ranges: %+v", info)
+	}
+
+	if !info.Gateway.Equal(net.ParseIP("10.1.0.14")) || ap.IfName != "eth0" {
+		t.Fatalf("pool gateway or interface set incorrectly: %+v", ap)
+	}
+	if len(info.DnsServers) != 1 || !info.DnsServers[0].Equal(net.ParseIP("10.1.0.2")) {
+		t.Fatalf("pool DNS servers set incorrectly: %v", info.DnsServers)
+	}
+
+	// The second pool contains only the listed addresses.
+	ap, err = as.getAddressPool("10.2.0.0/24")
+	if err != nil {
+		t.Fatalf("pool 10.2.0.0/24 not found: %v", err)
+	}
+
+	expected = []string{"10.2.0.16", "10.2.0.17", "10.2.0.18", "10.2.0.19", "10.2.0.4"}
+	if actual := sortedAddresses(ap); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("pool addresses did not match. expected: %v, actual: %v", expected, actual)
+	}
+	if ap.Priority != 1 {
+		t.Fatalf("pool priority set incorrectly")
+	}
+
+	// A missing file does not modify the existing configuration.
+	source.filePath = "bad"
+	if err = source.refresh(); err == nil {
+		t.Fatalf("didn't throw error on nonexistent file")
+	}
+	if len(as.Pools) != 2 {
+		t.Fatalf("existing pools were modified")
+	}
+}
+
+// Replaces the contents of a file, ensuring its modification time changes.
+func writeFile(t *testing.T, filePath string, data []byte) {
+	info, err := os.Stat(filePath)
+
+	if err = ioutil.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatalf("Failed to write %v, err:%v", filePath, err)
+	}
+
+	if info != nil {
+		modTime := info.ModTime().Add(time.Second)
+		os.Chtimes(filePath, modTime, modTime)
+	}
+}
+
+// Returns the number of addresses in a file source test pool.
+func getFilePoolSize(am *addressManager, asId string, poolId string) int {
+	am.Lock()
+	defer am.Unlock()
+
+	as := am.AddrSpaces[asId]
+	if as == nil || as.Pools[poolId] == nil {
+		return 0
+	}
+
+	return len(as.Pools[poolId].Addresses)
+}
+
+func TestFileSourceReload(t *testing.T) {
+	defer setHostSubnets()()
+
+	dir, err := ioutil.TempDir("", "ipamconfig")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir, err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "azure-ipam.json")
+	writeFile(t, filePath, []byte(`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4"]}]}]}`))
+
+	am := &addressManager{AddrSpaces: make(map[string]*addressSpace)}
+	source := &fileSource{name: "File", filePath: filePath}
+	defer source.stop()
+
+	// Load the initial configuration.
+	if err = source.start(am); err != nil {
+		t.Fatalf("Failed to start file source, err:%v", err)
+	}
+
+	if err = am.applyUpdate(source.refresh); err != nil || getFilePoolSize(am, "local", "10.1.0.0/24") != 1 {
+		t.Fatalf("Failed to load file source, err:%v", err)
+	}
+
+	// Invalid files are refused as a whole, even if some of their address spaces are valid.
+	invalidFiles := []string{
+		`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4", "10.1.0.5"]}]}, ` +
+			`{"id": "global", "scope": "invalid", "pools": [{"subnet": "10.2.0.0/24"}]}]}`,
+		`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4", "10.1.0.5"]}]}, ` +
+			`{"id": "global", "scope": "global", "pools": [{"subnet": "10.1.0.0/28"}]}]}`,
+		`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4", "10.1.0.5"]}]}, ` +
+			`{"id": "local", "pools": [{"subnet": "10.2.0.0/24"}]}]}`,
+	}
+
+	for _, data := range invalidFiles {
+		writeFile(t, filePath, []byte(data))
+
+		if err = am.applyUpdate(source.refresh); err == nil {
+			t.Errorf("Refresh succeeded with invalid file %s", data)
+		}
+
+		if getFilePoolSize(am, "local", "10.1.0.0/24") != 1 || am.AddrSpaces["global"] != nil {
+			t.Fatalf("Invalid file %s changed the configuration", data)
+		}
+	}
+
+	// Changes that keep the modification time are detected by size.
+	writeFile(t, filePath, []byte(`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4"]}]}]}`))
+	if err = am.applyUpdate(source.refresh); err != nil {
+		t.Fatalf("Failed to refresh file source, err:%v", err)
+	}
+
+	info, _ := os.Stat(filePath)
+	if err = ioutil.WriteFile(filePath, []byte(`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4/31"]}]}]}`), 0644); err != nil {
+		t.Fatalf("Failed to write %v, err:%v", filePath, err)
+	}
+	os.Chtimes(filePath, info.ModTime(), info.ModTime())
+
+	if err = am.applyUpdate(source.refresh); err != nil || getFilePoolSize(am, "local", "10.1.0.0/24") != 2 {
+		t.Fatalf("File source did not reload the changed file, err:%v", err)
+	}
+
+	// Valid changes are applied without waiting for a refresh where the file can be watched.
+	writeFile(t, filePath, []byte(`{"addressSpaces": [{"id": "local", "pools": [{"subnet": "10.1.0.0/24", "addresses": ["10.1.0.4/30"]}]}]}`))
+
+	if source.stopWatcher == nil {
+		if err = am.applyUpdate(source.refresh); err != nil {
+			t.Fatalf("Failed to refresh file source, err:%v", err)
+		}
+	}
+
+	for i := 0; i < 100 && getFilePoolSize(am, "local", "10.1.0.0/24") != 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if getFilePoolSize(am, "local", "10.1.0.0/24") != 4 {
+		t.Fatalf("File source did not reload the changed file")
+	}
+}
+
+func TestParseAddressRange(t *testing.T) {
+	r, err := parseAddressRange("10.0.0.1")
+	if err != nil || r.String() != "10.0.0.1/32" {
+		t.Fatalf("failed to parse single address: %v %v", r, err)
+	}
+
+	r, err = parseAddressRange("10.0.0.0/30")
+	if err != nil || r.String() != "10.0.0.0/30" {
+		t.Fatalf("failed to parse CIDR range: %v %v", r, err)
+	}
+
+	if _, err = parseAddressRange("invalid"); err == nil {
+		t.Fatalf("didn't throw error on invalid range")
+	}
+}
+
+// Returns the sorted string representations of the addresses in a pool.
+func sortedAddresses(ap *addressPool) []string {
+	var addrs []string
+	for k := range ap.Addresses {
+		addrs = append(addrs, k)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
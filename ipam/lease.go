@@ -0,0 +1,136 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Interval between scans for expired leases. Overridden by tests.
+var leaseReclaimInterval = 30 * time.Second
+
+// Parses the lease duration option, in seconds.
+func parseLeaseDuration(options map[string]string) (time.Duration, error) {
+	duration, ok := options[OptLeaseDuration]
+	if !ok {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(duration)
+	if err != nil || i < 0 {
+		log.Printf("[ipam] Invalid lease duration %v.", duration)
+		return 0, errInvalidLeaseDuration
+	}
+
+	return time.Duration(i) * time.Second, nil
+}
+
+// Returns whether an in-use address record's lease has expired.
+func (ar *addressRecord) isLeaseExpired(now time.Time) bool {
+	return ar.InUse && ar.LeaseDuration > 0 && !now.Before(ar.LeaseExpiry)
+}
+
+// Starts or extends the lease on an address record.
+func (ar *addressRecord) renewLease(now time.Time) {
+	if ar.LeaseDuration > 0 {
+		ar.LeaseExpiry = now.Add(ar.LeaseDuration)
+	} else {
+		ar.LeaseExpiry = time.Time{}
+	}
+}
+
+// Releases in-use addresses whose leases have expired.
+func (ap *addressPool) releaseExpiredLeases(now time.Time) []*ReclaimedAddressInfo {
+	var reclaimed []*ReclaimedAddressInfo
+
+	for _, ar := range ap.Addresses {
+		if !ar.isLeaseExpired(now) {
+			continue
+		}
+
+		info := &ReclaimedAddressInfo{
+			AsId:    ap.as.Id,
+			PoolId:  ap.Id,
+			Address: ar.Addr,
+			ID:      ar.ID,
+		}
+
+		log.Printf("[ipam] Lease on address %v with ID %v expired at %v.", ar.Addr, ar.ID, ar.LeaseExpiry)
+
+		err := ap.releaseAddress(ar.Addr.String(), map[string]string{OptAddressID: ar.ID})
+		if err != nil {
+			log.Printf("[ipam] Failed to release expired address %v, err:%v.", ar.Addr, err)
+			continue
+		}
+
+		reclaimed = append(reclaimed, info)
+	}
+
+	return reclaimed
+}
+
+// Releases in-use addresses whose leases have expired in all address spaces.
+func (am *addressManager) releaseExpiredLeases() []*ReclaimedAddressInfo {
+	var reclaimed []*ReclaimedAddressInfo
+	now := time.Now()
+
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			reclaimed = append(reclaimed, ap.releaseExpiredLeases(now)...)
+		}
+	}
+
+	if len(reclaimed) > 0 {
+		log.Printf("[ipam] Released %d addresses with expired leases.", len(reclaimed))
+	}
+
+	return reclaimed
+}
+
+// Starts a timer that releases addresses with expired leases,
+// so that they are reclaimed even when no requests arrive.
+func (am *addressManager) startLeaseReclaimTimer() {
+	stop := make(chan struct{})
+	am.stopLeaseReclaim = stop
+	ticker := time.NewTicker(leaseReclaimInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				am.reclaimExpiredLeases()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stops the lease reclaim timer.
+func (am *addressManager) stopLeaseReclaimTimer() {
+	if am.stopLeaseReclaim != nil {
+		close(am.stopLeaseReclaim)
+		am.stopLeaseReclaim = nil
+	}
+}
+
+// Releases addresses with expired leases and persists the result.
+func (am *addressManager) reclaimExpiredLeases() {
+	am.Lock()
+	defer am.Unlock()
+
+	if len(am.releaseExpiredLeases()) == 0 {
+		return
+	}
+
+	err := am.save()
+	if err != nil {
+		log.Printf("[ipam] Failed to save state after releasing expired leases, err:%v.", err)
+	}
+}
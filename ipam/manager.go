@@ -4,6 +4,7 @@
 package ipam
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -16,16 +17,59 @@ import (
 const (
 	// IPAM store key.
 	storeKey = "IPAM"
+
+	// Schema version of the persisted address manager state.
+	schemaVersion = 1
 )
 
+// Schema of the persisted address manager state.
+var stateSchema = store.NewSchema(schemaVersion)
+
+func init() {
+	stateSchema.RegisterMigration(0, migrateAddressesInUse)
+}
+
+// Marks the addresses allocated with an ID as in use in state persisted before schema versioning.
+// Earlier versions marked only addresses allocated without an ID as in use, and never released
+// addresses allocated with an ID, so every address with an ID in their state is allocated.
+func migrateAddressesInUse(doc map[string]interface{}) error {
+	addrSpaces, _ := doc["AddressSpaces"].(map[string]interface{})
+	for _, as := range addrSpaces {
+		as, _ := as.(map[string]interface{})
+		pools, _ := as["Pools"].(map[string]interface{})
+
+		for _, ap := range pools {
+			ap, _ := ap.(map[string]interface{})
+			addresses, _ := ap["Addresses"].(map[string]interface{})
+
+			for _, ar := range addresses {
+				ar, ok := ar.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("Invalid address record %v", ar)
+				}
+
+				if id, _ := ar["ID"].(string); id != "" {
+					ar["InUse"] = true
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // AddressManager manages the set of address spaces and pools allocated to containers.
 type addressManager struct {
-	Version    string
-	TimeStamp  time.Time
-	AddrSpaces map[string]*addressSpace `json:"AddressSpaces"`
-	store      store.KeyValueStore
-	source     addressConfigSource
-	netApi     common.NetApi
+	Version           string
+	SchemaVersion     int
+	TimeStamp         time.Time
+	AddrSpaces        map[string]*addressSpace `json:"AddressSpaces"`
+	store             store.KeyValueStore
+	source            addressConfigSource
+	netApi            common.NetApi
+	exhaustionHandler func(*ExhaustionEvent)
+	exhaustionEvents  []*ExhaustionEvent
+	stopLeaseReclaim  chan struct{}
 	sync.Mutex
 }
 
@@ -45,6 +89,10 @@ type AddressManager interface {
 
 	RequestAddress(asId, poolId, address string, options map[string]string) (string, error)
 	ReleaseAddress(asId, poolId, address string, options map[string]string) error
+	ReleaseStaleAddresses(asId string, liveIds []string) ([]*ReclaimedAddressInfo, error)
+
+	GetMetrics(asId string) ([]*AddressSpaceMetrics, error)
+	SetExhaustionHandler(handler func(*ExhaustionEvent)) error
 }
 
 // AddressConfigSource configures the address pools managed by AddressManager.
@@ -58,6 +106,8 @@ type addressConfigSource interface {
 type addressConfigSink interface {
 	newAddressSpace(id string, scope int) (*addressSpace, error)
 	setAddressSpace(*addressSpace) error
+	setAddressSpaces([]*addressSpace) error
+	applyUpdate(update func() error) error
 }
 
 // Creates a new address manager.
@@ -83,12 +133,18 @@ func (am *addressManager) Initialize(config *common.PluginConfig, options map[st
 
 	// Start source.
 	err = am.StartSource(options)
+	if err != nil {
+		return err
+	}
 
-	return err
+	am.startLeaseReclaimTimer()
+
+	return nil
 }
 
 // Uninitialize cleans up address manager.
 func (am *addressManager) Uninitialize() {
+	am.stopLeaseReclaimTimer()
 	am.StopSource()
 }
 
@@ -115,7 +171,7 @@ func (am *addressManager) restore() error {
 	}
 
 	// Read any persisted state.
-	err = am.store.Read(storeKey, am)
+	err = stateSchema.Read(am.store, storeKey, am)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
 			log.Printf("[ipam] store key not found")
@@ -145,8 +201,15 @@ func (am *addressManager) restore() error {
 		log.Printf("[ipam] Rehydrating ipam state from persistent store")
 		for _, as := range am.AddrSpaces {
 			for _, ap := range as.Pools {
+				// Child pools do not survive a reboot.
+				if ap.isChild() {
+					delete(as.Pools, ap.Id)
+					continue
+				}
+
 				ap.as = as
 				ap.RefCount = 0
+				ap.DelegatedRanges = nil
 
 				for _, ar := range ap.Addresses {
 					ar.InUse = false
@@ -167,8 +230,9 @@ func (am *addressManager) save() error {
 		return nil
 	}
 
-	// Update time stamp.
+	// Update time stamp and schema version.
 	am.TimeStamp = time.Now()
+	am.SchemaVersion = schemaVersion
 
 	err := am.store.Write(storeKey, am)
 	if err == nil {
@@ -183,6 +247,9 @@ func (am *addressManager) save() error {
 func (am *addressManager) StartSource(options map[string]interface{}) error {
 	var err error
 
+	// Stop the current source, if any, before replacing it.
+	am.StopSource()
+
 	environment, _ := options[common.OptEnvironment].(string)
 
 	switch environment {
@@ -192,6 +259,9 @@ func (am *addressManager) StartSource(options map[string]interface{}) error {
 	case common.OptEnvironmentMAS:
 		am.source, err = newMasSource(options)
 
+	case common.OptEnvironmentFile:
+		am.source, err = newFileSource(options)
+
 	case "null":
 		am.source, err = newNullSource()
 
@@ -222,6 +292,19 @@ func (am *addressManager) StopSource() {
 	}
 }
 
+// Applies a configuration update pushed by a source outside of AddressManager API calls.
+func (am *addressManager) applyUpdate(update func() error) error {
+	am.Lock()
+	defer am.Unlock()
+
+	err := update()
+	if err != nil {
+		return err
+	}
+
+	return am.save()
+}
+
 // Signals configuration source to refresh.
 func (am *addressManager) refreshSource() {
 	if am.source != nil {
@@ -267,12 +350,21 @@ func (am *addressManager) RequestPool(asId, poolId, subPoolId string, options ma
 	defer am.Unlock()
 
 	am.refreshSource()
+	am.releaseExpiredLeases()
 
 	as, err := am.getAddressSpace(asId)
 	if err != nil {
 		return "", "", err
 	}
 
+	// Refuse specifically requested pools that overlap other subnets.
+	if ap := as.Pools[poolId]; ap != nil && subPoolId == "" && options[OptChildPrefixLength] == "" {
+		err = am.checkPoolOverlap(asId, ap)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
 	pool, err := as.requestPool(poolId, subPoolId, options, v6)
 	if err != nil {
 		return "", "", err
@@ -292,6 +384,7 @@ func (am *addressManager) ReleasePool(asId string, poolId string) error {
 	defer am.Unlock()
 
 	am.refreshSource()
+	am.releaseExpiredLeases()
 
 	as, err := am.getAddressSpace(asId)
 	if err != nil {
@@ -331,10 +424,12 @@ func (am *addressManager) GetPoolInfo(asId string, poolId string) (*AddressPoolI
 
 // RequestAddress reserves a new address from the address pool.
 func (am *addressManager) RequestAddress(asId, poolId, address string, options map[string]string) (string, error) {
+	defer am.dispatchExhaustionEvents()
 	am.Lock()
 	defer am.Unlock()
 
 	am.refreshSource()
+	am.releaseExpiredLeases()
 
 	as, err := am.getAddressSpace(asId)
 	if err != nil {
@@ -346,11 +441,36 @@ func (am *addressManager) RequestAddress(asId, poolId, address string, options m
 		return "", err
 	}
 
-	addr, err := ap.requestAddress(address, options)
+	// Probe for other hosts using a new address before handing it out.
+	if ap.detectsDuplicates() && ap.isNewAddressRequest(address, options) {
+		address, err = am.probeAvailableAddress(as, ap)
+
+		// The ID may have been assigned an address by a concurrent request in the meantime.
+		if err == nil && !ap.isNewAddressRequest("", options) {
+			address = ""
+		}
+	}
+
+	before := ap.getMetrics(time.Now())
+
+	var addr string
+	if err == nil {
+		addr, err = ap.requestAddress(address, options)
+	}
+
 	if err != nil {
+		if err == errNoAvailableAddresses {
+			am.notifyExhaustion(ap, ExhaustionReasonNoAddresses, before)
+		}
 		return "", err
 	}
 
+	// Report the pool once when its utilization crosses the high-water mark.
+	after := ap.getMetrics(time.Now())
+	if !before.isAboveHighWaterMark(ap.HighWaterMark) && after.isAboveHighWaterMark(ap.HighWaterMark) {
+		am.notifyExhaustion(ap, ExhaustionReasonHighWaterMark, after)
+	}
+
 	err = am.save()
 	if err != nil {
 		return "", err
@@ -365,6 +485,7 @@ func (am *addressManager) ReleaseAddress(asId string, poolId string, address str
 	defer am.Unlock()
 
 	am.refreshSource()
+	am.releaseExpiredLeases()
 
 	as, err := am.getAddressSpace(asId)
 	if err != nil {
@@ -388,3 +509,84 @@ func (am *addressManager) ReleaseAddress(asId string, poolId string, address str
 
 	return nil
 }
+
+// ReleaseStaleAddresses releases in-use addresses whose owners are not in the given set of live IDs.
+// All address spaces are scanned if asId is empty.
+func (am *addressManager) ReleaseStaleAddresses(asId string, liveIds []string) ([]*ReclaimedAddressInfo, error) {
+	var reclaimed []*ReclaimedAddressInfo
+
+	am.Lock()
+	defer am.Unlock()
+
+	am.refreshSource()
+	am.releaseExpiredLeases()
+
+	addrSpaces := am.AddrSpaces
+	if asId != "" {
+		as, err := am.getAddressSpace(asId)
+		if err != nil {
+			return nil, err
+		}
+		addrSpaces = map[string]*addressSpace{asId: as}
+	}
+
+	live := make(map[string]bool)
+	for _, id := range liveIds {
+		live[id] = true
+	}
+
+	for _, as := range addrSpaces {
+		for _, ap := range as.Pools {
+			reclaimed = append(reclaimed, ap.releaseStaleAddresses(live)...)
+		}
+	}
+
+	if len(reclaimed) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("[ipam] Released %d stale addresses.", len(reclaimed))
+
+	err := am.save()
+	if err != nil {
+		return nil, err
+	}
+
+	return reclaimed, nil
+}
+
+// GetMetrics returns utilization metrics for the given address space.
+// All address spaces are returned if asId is empty.
+func (am *addressManager) GetMetrics(asId string) ([]*AddressSpaceMetrics, error) {
+	var metrics []*AddressSpaceMetrics
+
+	am.Lock()
+	defer am.Unlock()
+
+	addrSpaces := am.AddrSpaces
+	if asId != "" {
+		as, err := am.getAddressSpace(asId)
+		if err != nil {
+			return nil, err
+		}
+		addrSpaces = map[string]*addressSpace{asId: as}
+	}
+
+	now := time.Now()
+	for _, as := range addrSpaces {
+		metrics = append(metrics, as.getMetrics(now))
+	}
+
+	return metrics, nil
+}
+
+// SetExhaustionHandler sets the handler invoked on address pool exhaustion events.
+// The handler is invoked after the address manager is unlocked.
+func (am *addressManager) SetExhaustionHandler(handler func(*ExhaustionEvent)) error {
+	am.Lock()
+	defer am.Unlock()
+
+	am.exhaustionHandler = handler
+
+	return nil
+}
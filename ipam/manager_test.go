@@ -4,11 +4,16 @@
 package ipam
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/store"
 )
 
 var (
@@ -32,6 +37,14 @@ var (
 	addr33  = net.IPv4(10, 0, 3, 3)
 )
 
+// Overrides the subnets configured on host interfaces, so that tests do not depend on
+// the host running them. Returns a function that restores the original.
+func setHostSubnets(subnets ...hostSubnet) func() {
+	saved := getHostSubnets
+	getHostSubnets = func() ([]hostSubnet, error) { return subnets, nil }
+	return func() { getHostSubnets = saved }
+}
+
 // createAddressManager creates an address manager with a simple test configuration.
 func createAddressManager() (AddressManager, error) {
 	var config common.PluginConfig
@@ -143,6 +156,8 @@ func cleanupTestAddressSpace(am AddressManager) error {
 
 // Tests address spaces are created and queried correctly.
 func TestAddressSpaceCreateAndGet(t *testing.T) {
+	defer setHostSubnets()()
+
 	// Start with the test address space.
 	am, err := createAddressManager()
 	if err != nil {
@@ -163,6 +178,8 @@ func TestAddressSpaceCreateAndGet(t *testing.T) {
 
 // Tests updating an existing address space adds new resources and removes stale ones.
 func TestAddressSpaceUpdate(t *testing.T) {
+	defer setHostSubnets()()
+
 	// Start with the test address space.
 	am, err := createAddressManager()
 	if err != nil {
@@ -243,6 +260,8 @@ func TestAddressSpaceUpdate(t *testing.T) {
 
 // Tests multiple wildcard address pool requests return separate pools.
 func TestAddressPoolRequestsForSeparatePools(t *testing.T) {
+	defer setHostSubnets()()
+
 	// Start with the test address space.
 	am, err := createAddressManager()
 	if err != nil {
@@ -279,6 +298,8 @@ func TestAddressPoolRequestsForSeparatePools(t *testing.T) {
 
 // Tests multiple identical address pool requests return the same pool and pools are referenced correctly.
 func TestAddressPoolRequestsForSamePool(t *testing.T) {
+	defer setHostSubnets()()
+
 	// Start with the test address space.
 	am, err := createAddressManager()
 	if err != nil {
@@ -321,6 +342,8 @@ func TestAddressPoolRequestsForSamePool(t *testing.T) {
 
 // Tests address requests from the same pool return separate addresses and releases work correctly.
 func TestAddressRequestsFromTheSamePool(t *testing.T) {
+	defer setHostSubnets()()
+
 	// Start with the test address space.
 	am, err := createAddressManager()
 	if err != nil {
@@ -371,3 +394,1219 @@ func TestAddressRequestsFromTheSamePool(t *testing.T) {
 		t.Errorf("ReleasePool failed, err:%v", err)
 	}
 }
+
+// Tests address allocation strategies select addresses in the expected order.
+func TestAddressAllocationStrategies(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	// Add addr13 to subnet1 so that it has three addresses.
+	amImpl := am.(*addressManager)
+	ap := amImpl.AddrSpaces[LocalDefaultAddressSpaceId].Pools[subnet1.String()]
+	ap.newAddressRecord(&addr13)
+
+	// Request subnet1 with the lowest free strategy.
+	options := map[string]string{OptAllocStrategy: AllocStrategyLowestFree}
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	requestAndCheck := func(expected net.IP) {
+		address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+		if err != nil {
+			t.Fatalf("RequestAddress failed, err:%v", err)
+		}
+
+		addr, _, _ := net.ParseCIDR(address)
+		if !addr.Equal(expected) {
+			t.Errorf("RequestAddress returned %v, expected %v.", addr, expected)
+		}
+	}
+
+	release := func(addr net.IP) {
+		err := am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), nil)
+		if err != nil {
+			t.Fatalf("ReleaseAddress failed, err:%v", err)
+		}
+	}
+
+	// Lowest free always returns the lowest available address.
+	requestAndCheck(addr11)
+	requestAndCheck(addr12)
+	release(addr11)
+	requestAndCheck(addr11)
+	release(addr11)
+	release(addr12)
+
+	// Round robin continues after the last allocated address.
+	ap.AllocStrategy = AllocStrategyRoundRobin
+	requestAndCheck(addr12)
+	requestAndCheck(addr13)
+	release(addr12)
+	release(addr13)
+	requestAndCheck(addr11)
+	release(addr11)
+
+	// Least recently released returns the address released the longest time ago.
+	ap.AllocStrategy = AllocStrategyLeastRecentlyReleased
+	requestAndCheck(addr12)
+	requestAndCheck(addr13)
+	requestAndCheck(addr11)
+
+	// Requests that share the pool cannot change its strategy.
+	options[OptAllocStrategy] = AllocStrategyAny
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != errPoolSettingsConflict {
+		t.Errorf("RequestPool changed the allocation strategy of a pool in use, err:%v.", err)
+	}
+
+	// The strategy applies only to the requested pool.
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+	ap2 := amImpl.AddrSpaces[LocalDefaultAddressSpaceId].Pools[subnet2.String()]
+	if ap2.getAllocStrategy() != AllocStrategyAny {
+		t.Errorf("Pool has allocation strategy %v, expected %v.", ap2.getAllocStrategy(), AllocStrategyAny)
+	}
+
+	// Invalid strategies are rejected.
+	options[OptAllocStrategy] = "invalid"
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err == nil {
+		t.Errorf("RequestPool succeeded with an invalid allocation strategy.")
+	}
+}
+
+// Tests released addresses are quarantined and that quarantine survives a restore.
+func TestAddressQuarantine(t *testing.T) {
+	defer setHostSubnets()()
+
+	var config common.PluginConfig
+
+	// Back the address manager with a store.
+	storeFileName := "ipam-quarantine-test.json"
+	defer os.Remove(storeFileName)
+
+	kvs, err := store.NewJsonFileStoreWithBackups(storeFileName, 0)
+	if err != nil {
+		t.Fatalf("NewJsonFileStoreWithBackups failed, err:%v", err)
+	}
+	config.Store = kvs
+
+	am, err := NewAddressManager()
+	if err != nil {
+		t.Fatalf("NewAddressManager failed, err:%v", err)
+	}
+
+	err = am.Initialize(&config, nil)
+	if err != nil {
+		t.Fatalf("Initialize failed, err:%v", err)
+	}
+
+	err = setupTestAddressSpace(am)
+	if err != nil {
+		t.Fatalf("setupTestAddressSpace failed, err:%v", err)
+	}
+
+	// Request subnet1 with a quarantine interval and lowest free strategy.
+	options := map[string]string{
+		OptAllocStrategy:      AllocStrategyLowestFree,
+		OptQuarantineInterval: "3600",
+	}
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	if !addr.Equal(addr11) {
+		t.Fatalf("RequestAddress returned %v, expected %v.", addr, addr11)
+	}
+
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, addr11.String(), nil)
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+
+	// Restore the address manager from the store.
+	am, err = NewAddressManager()
+	if err != nil {
+		t.Fatalf("NewAddressManager failed, err:%v", err)
+	}
+
+	err = am.Initialize(&config, nil)
+	if err != nil {
+		t.Fatalf("Initialize failed, err:%v", err)
+	}
+
+	// The quarantined addr11 should be skipped in favor of addr12.
+	address, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ = net.ParseCIDR(address)
+	if !addr.Equal(addr12) {
+		t.Errorf("RequestAddress returned %v, expected %v.", addr, addr12)
+	}
+
+	// The pool is otherwise exhausted, so addr11 is reused.
+	address, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ = net.ParseCIDR(address)
+	if !addr.Equal(addr11) {
+		t.Errorf("RequestAddress returned %v, expected %v.", addr, addr11)
+	}
+
+	// Requests that share the pool cannot change its quarantine interval.
+	options[OptQuarantineInterval] = "0"
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != errPoolSettingsConflict {
+		t.Errorf("RequestPool changed the quarantine interval of a pool in use, err:%v.", err)
+	}
+
+	// The quarantine interval applies only to the requested pool.
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+	ap2 := am.(*addressManager).AddrSpaces[LocalDefaultAddressSpaceId].Pools[subnet2.String()]
+	if ap2.QuarantineInterval != 0 {
+		t.Errorf("Pool has quarantine interval %v, expected none.", ap2.QuarantineInterval)
+	}
+}
+
+// Tests excluded addresses are never allocated and are not reported as capacity.
+func TestExcludedAddresses(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	// Request subnet1 with addr11 excluded.
+	options := map[string]string{OptExcludedAddresses: addr11.String()}
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, poolId)
+	if err != nil {
+		t.Fatalf("GetPoolInfo failed, err:%v", err)
+	}
+
+	if info.Capacity != 1 || len(info.ExcludedRanges) != 1 {
+		t.Errorf("GetPoolInfo returned invalid capacity %+v.", info)
+	}
+
+	// The explicitly requested excluded address is refused.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr11.String(), nil)
+	if err == nil {
+		t.Errorf("RequestAddress returned excluded address %v.", addr11)
+	}
+
+	// Only addr12 is available.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	if !addr.Equal(addr12) {
+		t.Errorf("RequestAddress returned %v, expected %v.", addr, addr12)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err == nil {
+		t.Errorf("RequestAddress returned an excluded address.")
+	}
+
+	// Invalid ranges are rejected.
+	options[OptExcludedAddresses] = "invalid"
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err == nil {
+		t.Errorf("RequestPool succeeded with invalid excluded addresses.")
+	}
+}
+
+// Tests addresses owned by IDs that are no longer live are reclaimed.
+func TestReleaseStaleAddresses(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// Allocate an address to a live container and one to a leaked container.
+	liveAddress, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "live"})
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	leakedAddress, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "leaked"})
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	// The pool is exhausted.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err == nil {
+		t.Fatalf("RequestAddress succeeded on an exhausted pool.")
+	}
+
+	reclaimed, err := am.ReleaseStaleAddresses("", []string{"live"})
+	if err != nil {
+		t.Fatalf("ReleaseStaleAddresses failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(leakedAddress)
+	if len(reclaimed) != 1 || !reclaimed[0].Address.Equal(addr) || reclaimed[0].ID != "leaked" {
+		t.Fatalf("ReleaseStaleAddresses returned invalid report %+v.", reclaimed)
+	}
+
+	// The leaked address is available again, while the live address is kept.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil || address != leakedAddress {
+		t.Errorf("RequestAddress returned %v, expected %v, err:%v.", address, leakedAddress, err)
+	}
+
+	address, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "live"})
+	if err != nil || address != liveAddress {
+		t.Errorf("RequestAddress returned %v, expected %v, err:%v.", address, liveAddress, err)
+	}
+}
+
+// Tests that addresses allocated with an ID in state persisted before schema versioning are restored as in use.
+func TestRestoreUnversionedAddresses(t *testing.T) {
+	defer setHostSubnets()()
+
+	var config common.PluginConfig
+
+	storeFileName := "ipam-unversioned-test.json"
+	defer os.Remove(storeFileName)
+
+	kvs, err := store.NewJsonFileStoreWithBackups(storeFileName, 0)
+	if err != nil {
+		t.Fatalf("NewJsonFileStoreWithBackups failed, err:%v", err)
+	}
+	config.Store = kvs
+
+	// Earlier versions did not mark the address allocated with an ID as in use.
+	state := `{
+		"Version": "v1.0.0",
+		"AddressSpaces": {
+			"local": {
+				"Id": "local",
+				"Scope": 0,
+				"Pools": {
+					"10.0.1.0/24": {
+						"Id": "10.0.1.0/24",
+						"IfName": "eth0",
+						"Subnet": {"IP": "10.0.1.0", "Mask": "////AA=="},
+						"Gateway": "10.0.1.1",
+						"Addresses": {
+							"10.0.1.1": {"ID": "nc1", "Addr": "10.0.1.1", "InUse": false},
+							"10.0.1.2": {"ID": "", "Addr": "10.0.1.2", "InUse": false}
+						},
+						"RefCount": 1
+					}
+				}
+			}
+		}
+	}`
+
+	err = kvs.Write(storeKey, json.RawMessage(state))
+	if err != nil {
+		t.Fatalf("Write failed, err:%v", err)
+	}
+
+	am, err := NewAddressManager()
+	if err != nil {
+		t.Fatalf("NewAddressManager failed, err:%v", err)
+	}
+
+	err = am.Initialize(&config, nil)
+	if err != nil {
+		t.Fatalf("Initialize failed, err:%v", err)
+	}
+
+	poolId := subnet1.String()
+
+	// The address of nc1 is not handed out to others.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil || address != "10.0.1.2/24" {
+		t.Fatalf("RequestAddress returned %v, expected 10.0.1.2/24, err:%v.", address, err)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err == nil {
+		t.Fatalf("RequestAddress succeeded on an exhausted pool.")
+	}
+
+	// The address of nc1 can be released by its ID.
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "nc1"})
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+
+	address, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil || address != "10.0.1.1/24" {
+		t.Errorf("RequestAddress returned %v, expected 10.0.1.1/24, err:%v.", address, err)
+	}
+}
+
+// Tests that garbage collection keeps addresses reserved for a pod while their container is live,
+// and keeps the reservation of the pod when the container leaks.
+func TestReleaseStaleReservedAddresses(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	options := map[string]string{
+		OptAddressID:      "default/web-0",
+		OptAddressOwner:   "container1",
+		OptReservationTTL: "3600",
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	// The address is matched by its owner, not by the reservation ID.
+	reclaimed, err := am.ReleaseStaleAddresses("", []string{"container1"})
+	if err != nil || len(reclaimed) != 0 {
+		t.Fatalf("ReleaseStaleAddresses reclaimed the address of a live container %+v, err:%v.", reclaimed, err)
+	}
+
+	reclaimed, err = am.ReleaseStaleAddresses("", nil)
+	if err != nil || len(reclaimed) != 1 || reclaimed[0].ID != "default/web-0" || reclaimed[0].Owner != "container1" {
+		t.Fatalf("ReleaseStaleAddresses returned invalid report %+v, err:%v.", reclaimed, err)
+	}
+
+	// The reclaimed address stays reserved for the pod.
+	other, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "other"})
+	if err != nil || other == address {
+		t.Errorf("RequestAddress returned %v, the reserved address %v to a different ID, err:%v.", other, address, err)
+	}
+
+	options[OptAddressOwner] = "container2"
+	address2, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil || address2 != address {
+		t.Errorf("RequestAddress returned %v, expected reserved address %v, err:%v.", address2, address, err)
+	}
+}
+
+// Tests addresses released with a reservation TTL are returned to the same ID.
+func TestAddressReservation(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	options := map[string]string{
+		OptAddressID:      "default/web-0",
+		OptReservationTTL: "3600",
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), nil)
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+
+	// The reserved address is not handed out to a different ID.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), map[string]string{OptAddressID: "other"})
+	if err == nil {
+		t.Errorf("RequestAddress returned an address reserved for a different ID.")
+	}
+
+	other, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "other"})
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if other == address {
+		t.Errorf("RequestAddress returned the reserved address %v to a different ID.", address)
+	}
+
+	// The same ID gets the reserved address back.
+	address2, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if address2 != address {
+		t.Errorf("RequestAddress returned %v, expected reserved address %v.", address2, address)
+	}
+
+	// An expired reservation makes the address available to other IDs.
+	ap := am.(*addressManager).AddrSpaces[LocalDefaultAddressSpaceId].Pools[poolId]
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), nil)
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+	ap.Addresses[addr.String()].ReservedUntil = time.Now().Add(-time.Second)
+
+	address3, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "third"})
+	if err != nil || address3 != address {
+		t.Errorf("RequestAddress returned %v, expected %v, err:%v.", address3, address, err)
+	}
+
+	if ap.addrsByID["default/web-0"] != nil {
+		t.Errorf("Expired reservation was not removed.")
+	}
+}
+
+// Tests addresses found in use on the network are marked unhealthy and skipped.
+func TestDuplicateAddressDetection(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	// Report addr11 as a duplicate.
+	// Probes run without the lock, so that other requests are not blocked meanwhile.
+	amImpl := am.(*addressManager)
+	probeDuplicateAddress = func(ifName string, addr net.IP, v6 bool) (bool, error) {
+		unlocked := make(chan struct{})
+		go func() {
+			amImpl.Lock()
+			amImpl.Unlock()
+			close(unlocked)
+		}()
+
+		select {
+		case <-unlocked:
+		case <-time.After(time.Second):
+			t.Errorf("Address manager locked while probing address %v.", addr)
+		}
+
+		return addr.Equal(addr11), nil
+	}
+	defer func() { probeDuplicateAddress = probeAddress }()
+
+	options := map[string]string{
+		OptAllocStrategy:    AllocStrategyLowestFree,
+		OptDetectDuplicates: "true",
+	}
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	if !addr.Equal(addr12) {
+		t.Errorf("RequestAddress returned %v, expected %v.", addr, addr12)
+	}
+
+	info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, poolId)
+	if err != nil {
+		t.Fatalf("GetPoolInfo failed, err:%v", err)
+	}
+
+	if len(info.UnhealthyAddrs) != 1 || !info.UnhealthyAddrs[0].Equal(addr11) {
+		t.Errorf("GetPoolInfo returned invalid unhealthy addresses %v.", info.UnhealthyAddrs)
+	}
+
+	// The duplicate address is not handed out.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err == nil {
+		t.Errorf("RequestAddress returned a duplicate address.")
+	}
+
+	// Requests that share the pool cannot disable duplicate address detection.
+	options[OptDetectDuplicates] = "false"
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != errPoolSettingsConflict {
+		t.Errorf("RequestPool changed duplicate address detection of a pool in use, err:%v.", err)
+	}
+	options[OptDetectDuplicates] = "true"
+
+	// Addresses of other pools are not probed.
+	poolId2, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	probeDuplicateAddress = func(ifName string, addr net.IP, v6 bool) (bool, error) {
+		t.Errorf("Address %v probed in a pool without duplicate address detection.", addr)
+		return false, nil
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId2, "", nil)
+	if err != nil {
+		t.Errorf("RequestAddress failed, err:%v", err)
+	}
+
+	// Requests give up after a limited number of duplicates.
+	localAs, err := amImpl.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
+	if err != nil {
+		t.Fatalf("newAddressSpace failed, err:%v", err)
+	}
+
+	ap, err := localAs.newAddressPool(anyInterface, anyPriority, &subnet3)
+	if err != nil {
+		t.Fatalf("newAddressPool failed, err:%v", err)
+	}
+
+	for i := 1; i <= 14; i++ {
+		addr := net.IPv4(10, 0, 3, byte(i))
+		ap.newAddressRecord(&addr)
+	}
+
+	err = amImpl.setAddressSpace(localAs)
+	if err != nil {
+		t.Fatalf("setAddressSpace failed, err:%v", err)
+	}
+
+	poolId, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	probes := 0
+	probeDuplicateAddress = func(ifName string, addr net.IP, v6 bool) (bool, error) {
+		probes++
+		return true, nil
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != errDuplicateAddresses || probes != dadMaxCandidates {
+		t.Errorf("RequestAddress probed %v addresses, err:%v.", probes, err)
+	}
+}
+
+// setupDelegationAddressSpace creates a local address space with subnet3 populated with addresses 10.0.3.1-14.
+func setupDelegationAddressSpace(am AddressManager) error {
+	amImpl := am.(*addressManager)
+
+	localAs, err := amImpl.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
+	if err != nil {
+		return err
+	}
+
+	ap, err := localAs.newAddressPool(anyInterface, anyPriority, &subnet3)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 14; i++ {
+		addr := net.IPv4(10, 0, 3, byte(i))
+		ap.newAddressRecord(&addr)
+	}
+
+	return amImpl.setAddressSpace(localAs)
+}
+
+// Tests child prefixes are delegated from and returned to a parent pool.
+func TestChildPoolDelegation(t *testing.T) {
+	defer setHostSubnets()()
+
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	err = setupDelegationAddressSpace(am)
+	if err != nil {
+		t.Fatalf("setupDelegationAddressSpace failed, err:%+v.", err)
+	}
+
+	// Carve out the lowest free /30.
+	options := map[string]string{OptChildPrefixLength: "30"}
+	childId, childSubnet, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	if childId != "10.0.3.0/30" || childSubnet != "10.0.3.0/30" {
+		t.Fatalf("RequestPool returned invalid child pool %v %v.", childId, childSubnet)
+	}
+
+	// Delegate a specific /30.
+	childId2, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "10.0.3.4/30", nil, false)
+	if err != nil || childId2 != "10.0.3.4/30" {
+		t.Fatalf("RequestPool returned %v, err:%v", childId2, err)
+	}
+
+	// Overlapping and out-of-range prefixes are refused.
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "10.0.3.0/29", nil, false)
+	if err != errChildPrefixUnavailable {
+		t.Errorf("RequestPool delegated an overlapping prefix, err:%v", err)
+	}
+
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "10.0.4.0/30", nil, false)
+	if err != errInvalidChildPrefix {
+		t.Errorf("RequestPool delegated a prefix outside the pool, err:%v", err)
+	}
+
+	// Child pool addresses are requested through the normal path and keep the parent's mask.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, childId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, ipNet, _ := net.ParseCIDR(address)
+	_, childNet, _ := net.ParseCIDR(childSubnet)
+	if !childNet.Contains(addr) || ipNet.String() != subnet3.String() {
+		t.Errorf("RequestAddress returned %v outside the parent subnet.", address)
+	}
+
+	info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, childId)
+	if err != nil || info.Capacity != 3 || info.Available != 2 {
+		t.Errorf("GetPoolInfo returned invalid child pool info %+v, err:%v.", info, err)
+	}
+
+	// The parent no longer hands out delegated addresses.
+	info, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, subnet3.String())
+	if err != nil || info.Capacity != 7 {
+		t.Errorf("GetPoolInfo returned invalid parent pool info %+v, err:%v.", info, err)
+	}
+
+	// Child pools survive a source refresh.
+	err = setupDelegationAddressSpace(am)
+	if err != nil {
+		t.Fatalf("setupDelegationAddressSpace failed, err:%+v.", err)
+	}
+
+	_, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, childId)
+	if err != nil {
+		t.Fatalf("Child pool was deleted by refresh, err:%v", err)
+	}
+
+	// A child pool with addresses in use cannot be released.
+	err = am.ReleasePool(LocalDefaultAddressSpaceId, childId)
+	if err != errAddressPoolHasAddresses {
+		t.Errorf("ReleasePool released a child pool in use, err:%v", err)
+	}
+
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, childId, addr.String(), nil)
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+
+	err = am.ReleasePool(LocalDefaultAddressSpaceId, childId)
+	if err != nil {
+		t.Fatalf("ReleasePool failed, err:%v", err)
+	}
+
+	// The released prefix is returned to the parent.
+	_, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, childId)
+	if err == nil {
+		t.Errorf("Released child pool still exists.")
+	}
+
+	info, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, subnet3.String())
+	if err != nil || info.Capacity != 10 {
+		t.Errorf("GetPoolInfo returned invalid parent pool info %+v, err:%v.", info, err)
+	}
+
+	err = am.ReleasePool(LocalDefaultAddressSpaceId, childId2)
+	if err != nil {
+		t.Fatalf("ReleasePool failed, err:%v", err)
+	}
+
+	info, err = am.GetPoolInfo(LocalDefaultAddressSpaceId, subnet3.String())
+	if err != nil || info.Capacity != 14 {
+		t.Errorf("GetPoolInfo returned invalid parent pool info %+v, err:%v.", info, err)
+	}
+}
+
+// Tests utilization metrics are tracked and exhaustion events are reported.
+func TestAddressMetricsAndExhaustion(t *testing.T) {
+	defer setHostSubnets()()
+
+	var events []*ExhaustionEvent
+
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	am.SetExhaustionHandler(func(event *ExhaustionEvent) {
+		events = append(events, event)
+	})
+
+	options := map[string]string{OptHighWaterMark: "50"}
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// The first allocation crosses the high-water mark.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if len(events) != 1 || events[0].Reason != ExhaustionReasonHighWaterMark || events[0].Metrics.InUse != 1 {
+		t.Fatalf("High-water mark event not reported, events:%+v.", events)
+	}
+
+	// Allocations above the high-water mark are not reported again.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("High-water mark event reported twice, events:%+v.", events)
+	}
+
+	// Running out of addresses is reported.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != errNoAvailableAddresses {
+		t.Fatalf("RequestAddress succeeded on an exhausted pool, err:%v", err)
+	}
+
+	if len(events) != 2 || events[1].Reason != ExhaustionReasonNoAddresses || events[1].PoolId != poolId {
+		t.Fatalf("Exhaustion event not reported, events:%+v.", events)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	err = am.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), nil)
+	if err != nil {
+		t.Fatalf("ReleaseAddress failed, err:%v", err)
+	}
+
+	metrics, err := am.GetMetrics(LocalDefaultAddressSpaceId)
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("GetMetrics failed, metrics:%+v err:%v", metrics, err)
+	}
+
+	var pm *AddressPoolMetrics
+	for _, m := range metrics[0].Pools {
+		if m.PoolId == poolId {
+			pm = m
+		}
+	}
+
+	if pm == nil || pm.Capacity != 2 || pm.InUse != 1 || pm.Available != 1 ||
+		pm.Allocations != 2 || pm.Releases != 1 || pm.AllocationRate <= 0 || pm.ReleaseRate <= 0 {
+		t.Errorf("GetMetrics returned invalid pool metrics %+v.", pm)
+	}
+
+	// Address space metrics aggregate all pools, including subnet2.
+	if metrics[0].Capacity != 3 || metrics[0].InUse != 1 || metrics[0].Allocations != 2 {
+		t.Errorf("GetMetrics returned invalid address space metrics %+v.", metrics[0])
+	}
+
+	// All address spaces are returned if none is specified.
+	metrics, err = am.GetMetrics("")
+	if err != nil || len(metrics) != 2 {
+		t.Errorf("GetMetrics returned %v address spaces, err:%v.", len(metrics), err)
+	}
+
+	// Requests that share the pool cannot change its high-water mark.
+	options[OptHighWaterMark] = "90"
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", options, false)
+	if err != errPoolSettingsConflict {
+		t.Errorf("RequestPool changed the high-water mark of a pool in use, err:%v.", err)
+	}
+
+	// The high-water mark applies only to the requested pool.
+	poolId2, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	count := len(events)
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId2, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if len(events) != count {
+		t.Errorf("High-water mark event reported for a pool without a high-water mark, events:%+v.", events[count:])
+	}
+}
+
+// Tests event rates decay over time.
+func TestEventRate(t *testing.T) {
+	var r eventRate
+
+	now := time.Now()
+	if r.value(now) != 0 {
+		t.Errorf("Initial event rate is not zero.")
+	}
+
+	r.add(now)
+	r.add(now)
+
+	rate := r.value(now)
+	if r.Total != 2 || rate <= 0 {
+		t.Errorf("Event rate not recorded, total:%v rate:%v.", r.Total, rate)
+	}
+
+	if later := r.value(now.Add(time.Hour)); later >= rate/100 {
+		t.Errorf("Event rate did not decay, rate:%v later:%v.", rate, later)
+	}
+}
+
+// Tests leased addresses are renewed, and reclaimed once their leases expire.
+func TestAddressLeases(t *testing.T) {
+	defer setHostSubnets()()
+
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// Invalid lease durations are rejected.
+	options := map[string]string{OptAddressID: "leased", OptLeaseDuration: "invalid"}
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != errInvalidLeaseDuration {
+		t.Errorf("RequestAddress accepted an invalid lease duration, err:%v", err)
+	}
+
+	options[OptLeaseDuration] = "60"
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	addr, _, _ := net.ParseCIDR(address)
+	ap := am.(*addressManager).AddrSpaces[LocalDefaultAddressSpaceId].Pools[poolId]
+	ar := ap.Addresses[addr.String()]
+	if ar.LeaseExpiry.Before(time.Now().Add(59 * time.Second)) {
+		t.Errorf("Lease expiry set incorrectly %v.", ar.LeaseExpiry)
+	}
+
+	// Leases are renewed by requesting the address again with its ID, and only by their owner.
+	allocations := ap.Allocations.Total
+	ar.LeaseExpiry = time.Now().Add(time.Second)
+	address2, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil || address2 != address {
+		t.Fatalf("RequestAddress renewal returned %v, expected %v, err:%v", address2, address, err)
+	}
+
+	if ar.LeaseExpiry.Before(time.Now().Add(59 * time.Second)) {
+		t.Errorf("Lease was not renewed, expiry %v.", ar.LeaseExpiry)
+	}
+
+	if ap.Allocations.Total != allocations {
+		t.Errorf("Lease renewal was counted as an allocation.")
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), map[string]string{OptAddressID: "other"})
+	if err != errAddressInUse {
+		t.Errorf("RequestAddress renewed another ID's lease, err:%v", err)
+	}
+
+	// Addresses without leases never expire.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "unleased"})
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != errNoAvailableAddresses {
+		t.Fatalf("RequestAddress succeeded on an exhausted pool, err:%v", err)
+	}
+
+	// An expired lease is reclaimed by the next request.
+	ar.LeaseExpiry = time.Now().Add(-time.Second)
+
+	address2, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil || address2 != address {
+		t.Fatalf("RequestAddress returned %v, expected %v, err:%v", address2, address, err)
+	}
+
+	if ar.LeaseDuration != 0 || !ar.LeaseExpiry.IsZero() {
+		t.Errorf("Lease was not cleared on reallocation %+v.", ar)
+	}
+
+	// The former owner can no longer renew the lease.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr.String(), options)
+	if err != errAddressInUse {
+		t.Errorf("RequestAddress renewed an expired lease, err:%v", err)
+	}
+}
+
+// Tests expired leases are reclaimed by the timer without any requests.
+func TestAddressLeaseReclaimTimer(t *testing.T) {
+	defer setHostSubnets()()
+
+	defer func(interval time.Duration) { leaseReclaimInterval = interval }(leaseReclaimInterval)
+	leaseReclaimInterval = 10 * time.Millisecond
+
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+	defer am.Uninitialize()
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	options := map[string]string{OptAddressID: "leased", OptLeaseDuration: "60"}
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	amImpl := am.(*addressManager)
+	addr, _, _ := net.ParseCIDR(address)
+
+	isInUse := func() bool {
+		amImpl.Lock()
+		defer amImpl.Unlock()
+		return amImpl.AddrSpaces[LocalDefaultAddressSpaceId].Pools[poolId].Addresses[addr.String()].InUse
+	}
+
+	amImpl.Lock()
+	amImpl.AddrSpaces[LocalDefaultAddressSpaceId].Pools[poolId].Addresses[addr.String()].LeaseExpiry = time.Now()
+	amImpl.Unlock()
+
+	for i := 0; i < 100 && isInUse(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if isInUse() {
+		t.Errorf("Address with an expired lease was not reclaimed")
+	}
+}
+
+// Tests address pools are selected across interfaces according to the pool policy.
+func TestPoolSelectionPolicies(t *testing.T) {
+	defer setHostSubnets()()
+
+	var config common.PluginConfig
+
+	am, err := NewAddressManager()
+	if err != nil {
+		t.Fatalf("NewAddressManager failed, err:%+v.", err)
+	}
+
+	err = am.Initialize(&config, nil)
+	if err != nil {
+		t.Fatalf("Initialize failed, err:%+v.", err)
+	}
+
+	// Configure subnet1 on eth0, and subnet2 and subnet3 on the secondary interface eth1.
+	amImpl := am.(*addressManager)
+
+	localAs, err := amImpl.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
+	if err != nil {
+		t.Fatalf("newAddressSpace failed, err:%+v.", err)
+	}
+
+	ap, _ := localAs.newAddressPool("eth0", 0, &subnet1)
+	ap.newAddressRecord(&addr11)
+	ap.newAddressRecord(&addr12)
+
+	ap, _ = localAs.newAddressPool("eth1", 1, &subnet2)
+	ap.newAddressRecord(&addr21)
+	ap.newAddressRecord(&addr22)
+
+	ap, _ = localAs.newAddressPool("eth1", 1, &subnet3)
+	ap.newAddressRecord(&addr31)
+	ap.newAddressRecord(&addr32)
+
+	amImpl.setAddressSpace(localAs)
+
+	// Use an address on eth1.
+	poolId2, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId2, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	tests := []struct {
+		policy string
+		ifName string
+		poolId string
+		err    error
+	}{
+		{PoolPolicySpread, "", subnet1.String(), nil},
+		{PoolPolicyFill, "", subnet3.String(), nil},
+		{PoolPolicyPriority, "", subnet3.String(), nil},
+		{PoolPolicyPinned, "eth0", subnet1.String(), nil},
+		{PoolPolicyPinned, "", "", errInvalidPoolPolicy},
+		{"invalid", "", "", errInvalidPoolPolicy},
+	}
+
+	for _, test := range tests {
+		options := map[string]string{OptPoolPolicy: test.policy}
+		if test.ifName != "" {
+			options[OptInterfaceName] = test.ifName
+		}
+
+		poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", options, false)
+		if err != test.err || poolId != test.poolId {
+			t.Errorf("RequestPool with policy %v returned poolId:%v err:%v, expected poolId:%v err:%v",
+				test.policy, poolId, err, test.poolId, test.err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		// The chosen policy is visible in the pool information.
+		info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, poolId)
+		if err != nil || info.PoolPolicy != test.policy {
+			t.Errorf("GetPoolInfo returned info:%+v err:%v", info, err)
+		}
+
+		err = am.ReleasePool(LocalDefaultAddressSpaceId, poolId)
+		if err != nil {
+			t.Errorf("ReleasePool failed, err:%v", err)
+		}
+	}
+
+	// Requests without a policy use the default policy, regardless of earlier requests.
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", nil, false)
+	if err != nil || poolId != subnet3.String() {
+		t.Errorf("RequestPool without policy returned poolId:%v err:%v, expected poolId:%v",
+			poolId, err, subnet3.String())
+	}
+}
+
+// Tests overlapping pools are detected across address spaces and host interfaces.
+func TestOverlappingPools(t *testing.T) {
+	defer setHostSubnets()()
+
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	amImpl := am.(*addressManager)
+
+	// Host interfaces have subnets overlapping subnet2 and 10.0.5.0/24.
+	hostSubnet2 := net.IPNet{IP: net.IPv4(10, 0, 2, 128), Mask: net.CIDRMask(25, 32)}
+	hostSubnet5 := net.IPNet{IP: net.IPv4(10, 0, 5, 128), Mask: net.CIDRMask(25, 32)}
+	setHostSubnets(
+		hostSubnet{ifName: "docker0", subnet: hostSubnet2},
+		hostSubnet{ifName: "docker1", subnet: hostSubnet5})
+
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "docker0") {
+		t.Errorf("RequestPool of a pool overlapping a host interface returned err:%v", err)
+	}
+
+	_, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Errorf("RequestPool failed, err:%v", err)
+	}
+
+	// Address spaces with a pool overlapping subnet1, a pool overlapping a host interface
+	// or a pair of pools overlapping each other are refused as a whole.
+	tests := []struct {
+		subnets  []string
+		conflict string
+	}{
+		{[]string{"10.0.1.0/28", subnet3.String()}, subnet1.String() + " in address space " + LocalDefaultAddressSpaceId},
+		{[]string{"10.0.5.0/24", subnet3.String()}, hostSubnet5.String() + " on interface docker1"},
+		{[]string{"10.0.4.0/24", "10.0.4.128/25", subnet3.String()}, "10.0.4.128/25 in address space " + GlobalDefaultAddressSpaceId},
+	}
+
+	for _, test := range tests {
+		globalAs, err := amImpl.newAddressSpace(GlobalDefaultAddressSpaceId, GlobalScope)
+		if err != nil {
+			t.Fatalf("newAddressSpace failed, err:%v", err)
+		}
+
+		for _, s := range test.subnets {
+			_, subnet, _ := net.ParseCIDR(s)
+			globalAs.newAddressPool(anyInterface, anyPriority, subnet)
+		}
+
+		err = amImpl.setAddressSpace(globalAs)
+		if _, ok := err.(*poolOverlapError); !ok || !strings.HasSuffix(err.Error(), test.conflict) {
+			t.Errorf("setAddressSpace of pools %v returned err:%v, expected overlap with %v", test.subnets, err, test.conflict)
+		}
+
+		if len(amImpl.AddrSpaces[GlobalDefaultAddressSpaceId].Pools) != 0 {
+			t.Errorf("Address space with overlapping pools %v was set", test.subnets)
+		}
+	}
+
+	// Pools on the interface with the host subnet do not overlap it.
+	setHostSubnets(hostSubnet{ifName: "eth0", subnet: subnet3})
+
+	globalAs, err := amImpl.newAddressSpace(GlobalDefaultAddressSpaceId, GlobalScope)
+	if err != nil {
+		t.Fatalf("newAddressSpace failed, err:%v", err)
+	}
+
+	ap, _ := globalAs.newAddressPool("eth0", anyPriority, &subnet3)
+	ap.newAddressRecord(&addr31)
+
+	err = amImpl.setAddressSpace(globalAs)
+	if err != nil {
+		t.Fatalf("setAddressSpace failed, err:%v", err)
+	}
+
+	_, _, err = am.RequestPool(GlobalDefaultAddressSpaceId, subnet3.String(), "", nil, false)
+	if err != nil {
+		t.Errorf("RequestPool of a pool on the host interface failed, err:%v", err)
+	}
+}
@@ -6,12 +6,17 @@ package ipam
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/watch"
 )
 
 const (
@@ -22,11 +27,14 @@ const (
 )
 
 // Microsoft Azure Stack IPAM configuration source.
+// The mutex guards the sink and watcher, which are shared with the watcher goroutine.
 type masSource struct {
-	name       string
-	sink       addressConfigSink
-	fileLoaded bool
-	filePath   string
+	name        string
+	sink        addressConfigSink
+	filePath    string
+	modTime     time.Time
+	stopWatcher func()
+	sync.Mutex
 }
 
 // MAS host agent JSON object format.
@@ -41,8 +49,9 @@ type Interface struct {
 }
 
 type IPSubnet struct {
-	Prefix      string
-	IPAddresses []IPAddress
+	Prefix         string
+	IPAddresses    []IPAddress
+	ExcludedRanges []string `json:",omitempty"`
 }
 
 type IPAddress struct {
@@ -60,29 +69,92 @@ func newMasSource(options map[string]interface{}) (*masSource, error) {
 	}
 
 	return &masSource{
-		name: name,
+		name:     name,
 		filePath: filePath,
 	}, nil
 }
 
 // Starts the MAS source.
 func (source *masSource) start(sink addressConfigSink) error {
+	source.Lock()
+	defer source.Unlock()
+
 	source.sink = sink
+
+	// Reload the file as soon as it changes, instead of waiting for the next refresh.
+	// If the file cannot be watched, changes are still picked up on refresh.
+	stopWatcher, err := watch.File(source.filePath, source.onFileChanged)
+	if err != nil {
+		log.Printf("[ipam] Failed to watch %v, err:%v.", source.filePath, err)
+	} else {
+		source.stopWatcher = stopWatcher
+	}
+
 	return nil
 }
 
 // Stops the MAS source.
 func (source *masSource) stop() {
+	source.Lock()
+	defer source.Unlock()
+
+	if source.stopWatcher != nil {
+		source.stopWatcher()
+		source.stopWatcher = nil
+	}
+
 	source.sink = nil
 }
 
+// Handles change notifications for the SDN interfaces file.
+func (source *masSource) onFileChanged() {
+	sink := source.getSink()
+	if sink == nil {
+		return
+	}
+
+	log.Printf("[ipam] Detected change in %v.", source.filePath)
+
+	err := sink.applyUpdate(func() error {
+		return source.load(sink)
+	})
+	if err != nil {
+		log.Printf("[ipam] Failed to reload %v, keeping the last good configuration, err:%v.", source.filePath, err)
+	}
+}
+
+// Returns the sink of a started source, or nil if the source is stopped.
+func (source *masSource) getSink() addressConfigSink {
+	source.Lock()
+	defer source.Unlock()
+
+	return source.sink
+}
+
 // Refreshes configuration.
 func (source *masSource) refresh() error {
 	if source == nil {
 		return errors.New("masSource is nil")
 	}
 
-	if source.fileLoaded {
+	sink := source.getSink()
+	if sink == nil {
+		return nil
+	}
+
+	return source.load(sink)
+}
+
+// Loads the SDN interfaces file into the given sink.
+// Callers hold the lock of the sink, which also guards the modification time of the file.
+func (source *masSource) load(sink addressConfigSink) error {
+	// Reload only if the file changed since it was last loaded.
+	info, err := os.Stat(source.filePath)
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(source.modTime) {
 		return nil
 	}
 
@@ -92,14 +164,20 @@ func (source *masSource) refresh() error {
 		return err
 	}
 
-	// Query the list of Azure Network Interfaces
+	// Query the list of Azure Network Interfaces.
+	// The file is validated before it is applied, so that a partially written
+	// or otherwise invalid file leaves the last good configuration in place.
 	sdnInterfaces, err := getSDNInterfaces(source.filePath)
 	if err != nil {
 		return err
 	}
 
+	if err = validateSDNInterfaces(sdnInterfaces); err != nil {
+		return err
+	}
+
 	// Configure the local default address space.
-	local, err := source.sink.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
+	local, err := sink.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
 	if err != nil {
 		return err
 	}
@@ -108,13 +186,17 @@ func (source *masSource) refresh() error {
 		return err
 	}
 
+	if len(local.Pools) == 0 {
+		return fmt.Errorf("No interfaces in %v match local interfaces", source.filePath)
+	}
+
 	// Set the local address space as active.
-	if err = source.sink.setAddressSpace(local); err != nil {
+	if err = sink.setAddressSpace(local); err != nil {
 		return err
 	}
 
 	log.Printf("[ipam] Address space successfully populated from config file")
-	source.fileLoaded = true
+	source.modTime = info.ModTime()
 
 	return nil
 }
@@ -133,6 +215,41 @@ func getSDNInterfaces(fileLocation string) (*NetworkInterfaces, error) {
 	return interfaces, nil
 }
 
+// Validates the SDN interfaces read from the host agent file.
+func validateSDNInterfaces(sdnInterfaces *NetworkInterfaces) error {
+	if len(sdnInterfaces.Interfaces) == 0 {
+		return fmt.Errorf("No interfaces found")
+	}
+
+	for _, sdnIf := range sdnInterfaces.Interfaces {
+		if sdnIf.MacAddress == "" {
+			return fmt.Errorf("Interface has no MAC address")
+		}
+
+		for _, subnet := range sdnIf.IPSubnets {
+			_, network, err := net.ParseCIDR(subnet.Prefix)
+			if err != nil {
+				return fmt.Errorf("Invalid subnet %v on interface %v", subnet.Prefix, sdnIf.MacAddress)
+			}
+
+			for _, ipAddr := range subnet.IPAddresses {
+				address := net.ParseIP(ipAddr.Address)
+				if address == nil || !network.Contains(address) {
+					return fmt.Errorf("Invalid address %v in subnet %v", ipAddr.Address, subnet.Prefix)
+				}
+			}
+
+			for _, excluded := range subnet.ExcludedRanges {
+				if _, err := parseAddressRange(excluded); err != nil {
+					return fmt.Errorf("Invalid excluded range %v in subnet %v", excluded, subnet.Prefix)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func populateAddressSpace(localAddressSpace *addressSpace, sdnInterfaces *NetworkInterfaces, localInterfaces []net.Interface) error {
 	//Find the interface with matching MacAddress or Name
 	for _, sdnIf := range sdnInterfaces.Interfaces {
@@ -170,6 +287,16 @@ func populateAddressSpace(localAddressSpace *addressSpace, sdnInterfaces *Networ
 				continue
 			}
 
+			// Exclude the reserved ranges from allocation.
+			for _, excluded := range subnet.ExcludedRanges {
+				r, err := parseAddressRange(excluded)
+				if err != nil {
+					log.Printf("[ipam] Failed to parse excluded range:%v err:%v.", excluded, err)
+					continue
+				}
+				addressPool.SourceExcludedRanges = append(addressPool.SourceExcludedRanges, *r)
+			}
+
 			// Add the IP addresses to the localAddressSpace address space.
 			for _, ipAddr := range subnet.IPAddresses {
 				// Primary addresses are reserved for the host.
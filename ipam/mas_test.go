@@ -1,10 +1,15 @@
 package ipam
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestNewMasSource(t *testing.T) {
@@ -263,4 +268,148 @@ func TestPopulateAddressSpaceMultipleSDNInterfaces(t *testing.T) {
 	if pool.Priority != 1 {
 		t.Fatalf("Incorrect interface priority. expected: %d, actual %d", 1, pool.Priority)
 	}
-}
\ No newline at end of file
+}
+// Writes an SDN interfaces file with the given addresses on the interface with the given MAC address.
+func writeSDNInterfacesFile(t *testing.T, filePath string, macAddress string, addresses ...string) {
+	sdnInterfaces := &NetworkInterfaces{
+		Interfaces: []Interface{
+			{
+				MacAddress: macAddress,
+				IsPrimary:  true,
+				IPSubnets:  []IPSubnet{{Prefix: "10.1.0.0/24"}},
+			},
+		},
+	}
+
+	for _, address := range addresses {
+		sdnInterfaces.Interfaces[0].IPSubnets[0].IPAddresses = append(
+			sdnInterfaces.Interfaces[0].IPSubnets[0].IPAddresses, IPAddress{Address: address})
+	}
+
+	data, _ := json.Marshal(sdnInterfaces)
+	writeFile(t, filePath, data)
+}
+
+// Returns the number of addresses in the MAS test pool.
+func getMasPoolSize(am *addressManager) int {
+	am.Lock()
+	defer am.Unlock()
+
+	as := am.AddrSpaces[LocalDefaultAddressSpaceId]
+	if as == nil || as.Pools["10.1.0.0/24"] == nil {
+		return 0
+	}
+
+	return len(as.Pools["10.1.0.0/24"].Addresses)
+}
+
+func TestMasSourceReload(t *testing.T) {
+	defer setHostSubnets()()
+
+	var macAddress string
+	localInterfaces, _ := net.Interfaces()
+	for _, localIf := range localInterfaces {
+		if len(localIf.HardwareAddr) != 0 {
+			macAddress = localIf.HardwareAddr.String()
+			break
+		}
+	}
+
+	if macAddress == "" {
+		t.Skip("No local interfaces with MAC addresses found")
+	}
+
+	dir, err := ioutil.TempDir("", "mas")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir, err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "interfaces.json")
+	writeSDNInterfacesFile(t, filePath, macAddress, "10.1.0.4", "10.1.0.5")
+
+	am := &addressManager{AddrSpaces: make(map[string]*addressSpace)}
+	source := &masSource{name: name, filePath: filePath}
+	defer source.stop()
+
+	// Load the initial configuration.
+	if err = source.start(am); err != nil {
+		t.Fatalf("Failed to start MAS source, err:%v", err)
+	}
+
+	if err = am.applyUpdate(source.refresh); err != nil || getMasPoolSize(am) != 2 {
+		t.Fatalf("Failed to load MAS source, err:%v", err)
+	}
+
+	// Partially written and invalid files keep the last good configuration.
+	invalidFiles := [][]byte{
+		[]byte(`{"Interfaces": [{"MacAddress": "`),
+		[]byte(`{"Interfaces": []}`),
+		[]byte(`{"Interfaces": [{"MacAddress": "` + macAddress + `", "IPSubnets": [{"Prefix": "10.1.0.0/24", "IPAddresses": [{"Address": "10.2.0.4"}]}]}]}`),
+	}
+
+	for _, data := range invalidFiles {
+		writeFile(t, filePath, data)
+
+		if err = am.applyUpdate(source.refresh); err == nil {
+			t.Errorf("Refresh succeeded with invalid file %s", data)
+		}
+
+		if getMasPoolSize(am) != 2 {
+			t.Fatalf("Invalid file %s changed the configuration", data)
+		}
+	}
+
+	// Valid changes are applied without waiting for a refresh where the file can be watched.
+	writeSDNInterfacesFile(t, filePath, macAddress, "10.1.0.4", "10.1.0.5", "10.1.0.6")
+
+	if source.stopWatcher == nil {
+		if err = am.applyUpdate(source.refresh); err != nil {
+			t.Fatalf("Failed to refresh MAS source, err:%v", err)
+		}
+	}
+
+	for i := 0; i < 100 && getMasPoolSize(am) != 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if getMasPoolSize(am) != 3 {
+		t.Fatalf("MAS source did not reload the changed file")
+	}
+}
+
+func TestMasSourceStopWhileReloading(t *testing.T) {
+	defer setHostSubnets()()
+
+	dir, err := ioutil.TempDir("", "mas")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir, err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "interfaces.json")
+	writeSDNInterfacesFile(t, filePath, "00:0d:3a:00:00:01", "10.1.0.4")
+
+	am := &addressManager{AddrSpaces: make(map[string]*addressSpace)}
+	source := &masSource{name: name, filePath: filePath}
+
+	if err = source.start(am); err != nil {
+		t.Fatalf("Failed to start MAS source, err:%v", err)
+	}
+
+	// Change notifications racing with stop must neither use a cleared sink nor race on it.
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			source.onFileChanged()
+		}
+		close(done)
+	}()
+
+	source.stop()
+	<-done
+
+	if err = source.refresh(); err != nil {
+		t.Errorf("Refresh of stopped MAS source failed, err:%v", err)
+	}
+}
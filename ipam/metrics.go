@@ -0,0 +1,188 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"math"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Time constant of the exponentially decaying allocation and release rates.
+	rateTimeConstant = 5 * time.Minute
+
+	// Reasons for address pool exhaustion events.
+	ExhaustionReasonNoAddresses   = "NoAvailableAddresses"
+	ExhaustionReasonHighWaterMark = "HighWaterMarkCrossed"
+)
+
+// Counts events and tracks their exponentially decaying rate.
+// Persisted with the pool, since IPAM plugins may run as short-lived processes.
+type eventRate struct {
+	Total   uint64
+	Rate    float64
+	Updated time.Time
+}
+
+// AddressPoolMetrics contains utilization metrics for an address pool.
+type AddressPoolMetrics struct {
+	PoolId         string
+	Capacity       int
+	InUse          int
+	Available      int
+	Unhealthy      int
+	Quarantined    int
+	Allocations    uint64
+	Releases       uint64
+	AllocationRate float64
+	ReleaseRate    float64
+}
+
+// AddressSpaceMetrics contains utilization metrics for an address space and its pools.
+type AddressSpaceMetrics struct {
+	AsId           string
+	Capacity       int
+	InUse          int
+	Available      int
+	Unhealthy      int
+	Quarantined    int
+	Allocations    uint64
+	Releases       uint64
+	AllocationRate float64
+	ReleaseRate    float64
+	Pools          []*AddressPoolMetrics
+}
+
+// ExhaustionEvent reports an address pool that ran out of, or is running out of, addresses.
+type ExhaustionEvent struct {
+	AsId          string
+	PoolId        string
+	Reason        string
+	HighWaterMark int
+	Timestamp     time.Time
+	Metrics       *AddressPoolMetrics
+}
+
+// Records an event.
+func (r *eventRate) add(now time.Time) {
+	r.Rate = r.value(now) + 1/rateTimeConstant.Minutes()
+	r.Updated = now
+	r.Total++
+}
+
+// Returns the event rate per minute.
+func (r *eventRate) value(now time.Time) float64 {
+	if r.Updated.IsZero() {
+		return 0
+	}
+
+	elapsed := now.Sub(r.Updated)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return r.Rate * math.Exp(-elapsed.Minutes()/rateTimeConstant.Minutes())
+}
+
+// Returns the utilization metrics of an address pool.
+func (ap *addressPool) getMetrics(now time.Time) *AddressPoolMetrics {
+	m := &AddressPoolMetrics{
+		PoolId:         ap.Id,
+		Allocations:    ap.Allocations.Total,
+		Releases:       ap.Releases.Total,
+		AllocationRate: ap.Allocations.value(now),
+		ReleaseRate:    ap.Releases.value(now),
+	}
+
+	for _, ar := range ap.Addresses {
+		// Excluded addresses do not count towards capacity.
+		if ap.isExcluded(ar.Addr) {
+			continue
+		}
+
+		m.Capacity++
+
+		if ar.InUse {
+			m.InUse++
+		} else {
+			m.Available++
+			if ar.isQuarantined(now) {
+				m.Quarantined++
+			}
+		}
+
+		if ar.unhealthy || ar.isDuplicate(now) {
+			m.Unhealthy++
+		}
+	}
+
+	return m
+}
+
+// Returns the utilization metrics of an address space and its pools.
+func (as *addressSpace) getMetrics(now time.Time) *AddressSpaceMetrics {
+	m := &AddressSpaceMetrics{AsId: as.Id}
+
+	for _, ap := range as.Pools {
+		pm := ap.getMetrics(now)
+
+		m.Capacity += pm.Capacity
+		m.InUse += pm.InUse
+		m.Available += pm.Available
+		m.Unhealthy += pm.Unhealthy
+		m.Quarantined += pm.Quarantined
+		m.Allocations += pm.Allocations
+		m.Releases += pm.Releases
+		m.AllocationRate += pm.AllocationRate
+		m.ReleaseRate += pm.ReleaseRate
+		m.Pools = append(m.Pools, pm)
+	}
+
+	return m
+}
+
+// Returns whether a pool's utilization is at or above the address space's high-water mark.
+func (m *AddressPoolMetrics) isAboveHighWaterMark(highWaterMark int) bool {
+	return highWaterMark > 0 && m.Capacity > 0 && m.InUse*100 >= m.Capacity*highWaterMark
+}
+
+// Queues an address pool exhaustion event for the registered handler.
+// Must be called with the address manager locked.
+func (am *addressManager) notifyExhaustion(ap *addressPool, reason string, m *AddressPoolMetrics) {
+	event := &ExhaustionEvent{
+		AsId:          ap.as.Id,
+		PoolId:        ap.Id,
+		Reason:        reason,
+		HighWaterMark: ap.HighWaterMark,
+		Timestamp:     time.Now(),
+		Metrics:       m,
+	}
+
+	log.Printf("[ipam] Address pool %v exhaustion event %v, capacity:%v inUse:%v.",
+		ap.Id, reason, m.Capacity, m.InUse)
+
+	if am.exhaustionHandler != nil {
+		am.exhaustionEvents = append(am.exhaustionEvents, event)
+	}
+}
+
+// Invokes the registered handler on queued exhaustion events.
+// Handlers may block on I/O, so they are invoked with the address manager unlocked.
+func (am *addressManager) dispatchExhaustionEvents() {
+	am.Lock()
+	handler := am.exhaustionHandler
+	events := am.exhaustionEvents
+	am.exhaustionEvents = nil
+	am.Unlock()
+
+	if handler == nil {
+		return
+	}
+
+	for _, event := range events {
+		handler(event)
+	}
+}
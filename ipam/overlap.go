@@ -0,0 +1,170 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Represents a subnet configured on a host interface.
+type hostSubnet struct {
+	ifName string
+	subnet net.IPNet
+}
+
+// Error returned when an address pool overlaps another subnet.
+type poolOverlapError struct {
+	asId    string
+	poolId  string
+	overlap string
+}
+
+func (e *poolOverlapError) Error() string {
+	return fmt.Sprintf("Address pool %v in address space %v overlaps %v", e.poolId, e.asId, e.overlap)
+}
+
+// Returns the subnets configured on host interfaces.
+// Overridden by tests.
+var getHostSubnets = hostSubnets
+
+// Returns the subnets configured on non-loopback host interfaces.
+func hostSubnets() ([]hostSubnet, error) {
+	var subnets []hostSubnet
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+
+			subnet := net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}
+			subnets = append(subnets, hostSubnet{ifName: iface.Name, subnet: subnet})
+		}
+	}
+
+	return subnets, nil
+}
+
+// Returns whether two subnets have any addresses in common.
+func subnetsOverlap(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Returns whether two pools in the same address space are a parent pool and its delegated child.
+func isDelegation(a *addressPool, b *addressPool) bool {
+	return a.ParentId == b.Id || b.ParentId == a.Id
+}
+
+// Returns the first subnet that overlaps the given pool of an address space,
+// either a pool in another address space or a subnet on another host interface.
+// Returns an empty string if there is no overlap.
+func (am *addressManager) findOverlap(asId string, ap *addressPool, hosts []hostSubnet) string {
+	for _, as := range am.AddrSpaces {
+		if as.Id == asId {
+			continue
+		}
+
+		for _, pool := range as.Pools {
+			if subnetsOverlap(&ap.Subnet, &pool.Subnet) {
+				return pool.Subnet.String() + " in address space " + as.Id
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		// Pools on an interface include the subnet of its host address.
+		// The same subnet can also be reachable through more than one interface.
+		if host.ifName == ap.IfName || host.subnet.String() == ap.Subnet.String() {
+			continue
+		}
+
+		if subnetsOverlap(&ap.Subnet, &host.subnet) {
+			return host.subnet.String() + " on interface " + host.ifName
+		}
+	}
+
+	return ""
+}
+
+// Checks that the pools of an address space do not overlap each other, pools in other
+// address spaces or subnets on other host interfaces before it is set.
+// Overlapping pools would otherwise hand out the same address more than once.
+func (am *addressManager) checkAddressSpaceOverlap(as *addressSpace) error {
+	hosts, err := getHostSubnets()
+	if err != nil {
+		log.Printf("[ipam] Failed to query host interface subnets, err:%v.", err)
+	}
+
+	// Check pools in a stable order for consistent errors.
+	var poolIds []string
+	for poolId := range as.Pools {
+		poolIds = append(poolIds, poolId)
+	}
+	sort.Strings(poolIds)
+
+	for i, poolId := range poolIds {
+		ap := as.Pools[poolId]
+
+		if overlap := am.findOverlap(as.Id, ap, hosts); overlap != "" {
+			return &poolOverlapError{asId: as.Id, poolId: poolId, overlap: overlap}
+		}
+
+		for _, otherId := range poolIds[i+1:] {
+			other := as.Pools[otherId]
+			if !isDelegation(ap, other) && subnetsOverlap(&ap.Subnet, &other.Subnet) {
+				overlap := otherId + " in address space " + as.Id
+				return &poolOverlapError{asId: as.Id, poolId: poolId, overlap: overlap}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks that the pools of two address spaces set together do not overlap each other.
+func checkAddressSpacesOverlap(as *addressSpace, other *addressSpace) error {
+	for _, ap := range as.Pools {
+		for _, otherAp := range other.Pools {
+			if subnetsOverlap(&ap.Subnet, &otherAp.Subnet) {
+				overlap := otherAp.Id + " in address space " + other.Id
+				return &poolOverlapError{asId: as.Id, poolId: ap.Id, overlap: overlap}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks that a specifically requested pool does not overlap other pools or host interface subnets.
+func (am *addressManager) checkPoolOverlap(asId string, ap *addressPool) error {
+	hosts, err := getHostSubnets()
+	if err != nil {
+		log.Printf("[ipam] Failed to query host interface subnets, err:%v.", err)
+	}
+
+	if overlap := am.findOverlap(asId, ap, hosts); overlap != "" {
+		return &poolOverlapError{asId: asId, poolId: ap.Id, overlap: overlap}
+	}
+
+	return nil
+}
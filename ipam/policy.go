@@ -0,0 +1,98 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"time"
+)
+
+// Address utilization of an interface, summed over its pools.
+type interfaceUsage struct {
+	capacity int
+	inUse    int
+}
+
+// Returns whether the given pool selection policy is supported.
+func isValidPoolPolicy(policy string) bool {
+	switch policy {
+	case PoolPolicyPriority, PoolPolicySpread, PoolPolicyFill, PoolPolicyPinned:
+		return true
+	}
+
+	return false
+}
+
+// Returns the address utilization of each interface in the address space.
+func (as *addressSpace) getInterfaceUsage(now time.Time) map[string]*interfaceUsage {
+	usage := make(map[string]*interfaceUsage)
+
+	for _, ap := range as.Pools {
+		u := usage[ap.IfName]
+		if u == nil {
+			u = &interfaceUsage{}
+			usage[ap.IfName] = u
+		}
+
+		m := ap.getMetrics(now)
+		u.capacity += m.Capacity
+		u.inUse += m.InUse
+	}
+
+	return usage
+}
+
+// Compares the utilization of two interfaces.
+// Returns a negative number if a is less utilized than b, zero if equal, and positive otherwise.
+func (a *interfaceUsage) compare(b *interfaceUsage) int {
+	// Interfaces without capacity are treated as fully utilized.
+	if a.capacity == 0 || b.capacity == 0 {
+		return b.capacity - a.capacity
+	}
+
+	return a.inUse*b.capacity - b.inUse*a.capacity
+}
+
+// Returns whether pool a is preferred over pool b under the given policy.
+// Both pools have already been checked against the request's requirements.
+func (as *addressSpace) isPreferredPool(policy string, a *addressPool, b *addressPool, usage map[string]*interfaceUsage) bool {
+	switch policy {
+	case PoolPolicySpread, PoolPolicyFill:
+		// Pools with no available addresses are never preferred.
+		aFree := a.getInfo().Available
+		bFree := b.getInfo().Available
+		if (aFree == 0) != (bFree == 0) {
+			return aFree > 0
+		}
+
+		if a.IfName != b.IfName {
+			c := usage[a.IfName].compare(usage[b.IfName])
+
+			// Spread prefers the least utilized interface, fill the most utilized one.
+			if c != 0 {
+				if policy == PoolPolicySpread {
+					return c < 0
+				}
+				return c > 0
+			}
+
+			// Break ties deterministically, so that fill keeps filling the same interface.
+			if a.Priority != b.Priority {
+				return a.Priority > b.Priority
+			}
+
+			return a.IfName < b.IfName
+		}
+
+		// Prefer the pool with the most available addresses on the same interface.
+		if aFree != bFree {
+			return aFree > bFree
+		}
+
+		return a.Priority > b.Priority
+
+	default:
+		// Prefer the pool with the highest priority, then the highest number of addresses.
+		return a.Priority > b.Priority || len(a.Addresses) > len(b.Addresses)
+	}
+}
@@ -6,7 +6,9 @@ package ipam
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
@@ -51,37 +53,71 @@ type addressSpace struct {
 
 // Represents a subnet and the set of addresses in it.
 type addressPool struct {
-	as        *addressSpace
-	Id        string
-	IfName    string
-	Subnet    net.IPNet
-	Gateway   net.IP
-	Addresses map[string]*addressRecord
-	addrsByID map[string]*addressRecord
-	IsIPv6    bool
-	Priority  int
-	RefCount  int
-	epoch     int
+	as                   *addressSpace
+	Id                   string
+	IfName               string
+	Subnet               net.IPNet
+	Gateway              net.IP
+	DnsServers           []net.IP
+	ExcludedRanges       []net.IPNet
+	SourceExcludedRanges []net.IPNet
+	ParentId             string
+	DelegatedRanges      []net.IPNet
+	Addresses            map[string]*addressRecord
+	addrsByID            map[string]*addressRecord
+	IsIPv6               bool
+	Priority             int
+	PoolPolicy           string
+	AllocStrategy        string
+	QuarantineInterval   time.Duration
+	DetectDuplicates     bool
+	HighWaterMark        int
+	RefCount             int
+	LastAddr             net.IP
+	Allocations          eventRate
+	Releases             eventRate
+	epoch                int
 }
 
 // AddressPoolInfo contains information about an address pool.
 type AddressPoolInfo struct {
 	Subnet         net.IPNet
+	IfName         string
+	PoolPolicy     string
 	Gateway        net.IP
 	DnsServers     []net.IP
 	UnhealthyAddrs []net.IP
+	ExcludedRanges []net.IPNet
 	IsIPv6         bool
 	Available      int
 	Capacity       int
 }
 
+// ReclaimedAddressInfo contains information about an address released by garbage collection.
+type ReclaimedAddressInfo struct {
+	AsId    string
+	PoolId  string
+	Address net.IP
+	ID      string
+	Owner   string
+}
+
 // Represents an IP address in a pool.
 type addressRecord struct {
-	ID        string
-	Addr      net.IP
-	InUse     bool
-	unhealthy bool
-	epoch     int
+	ID              string
+	Owner           string
+	Addr            net.IP
+	InUse           bool
+	ReleaseTime     time.Time
+	QuarantineUntil time.Time
+	ReservationTTL  time.Duration
+	ReservedUntil   time.Time
+	DuplicateUntil  time.Time
+	LeaseDuration   time.Duration
+	LeaseExpiry     time.Time
+	unhealthy       bool
+	tentative       bool
+	epoch           int
 }
 
 //
@@ -126,6 +162,55 @@ func (pid *addressPoolId) String() string {
 	return s
 }
 
+// Returns the ID of the address pool that addresses are allocated from.
+// This is the child subnet for delegated child pools, and the subnet otherwise.
+func (pid *addressPoolId) PoolId() string {
+	if pid.ChildSubnet != "" {
+		return pid.ChildSubnet
+	}
+	return pid.Subnet
+}
+
+// Parses an address range in CIDR notation or a single IP address.
+func parseAddressRange(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, r, err := net.ParseCIDR(s)
+		return r, err
+	}
+
+	addr := net.ParseIP(s)
+	if addr == nil {
+		return nil, fmt.Errorf("Invalid address %v", s)
+	}
+
+	if addr.To4() != nil {
+		return &net.IPNet{IP: addr.To4(), Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: addr, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Parses a comma-separated list of address ranges.
+func parseAddressRanges(s string) ([]net.IPNet, error) {
+	var ranges []net.IPNet
+
+	for _, rs := range strings.Split(s, ",") {
+		rs = strings.TrimSpace(rs)
+		if rs == "" {
+			continue
+		}
+
+		r, err := parseAddressRange(rs)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, *r)
+	}
+
+	return ranges, nil
+}
+
 //
 // AddressSpace
 //
@@ -155,17 +240,39 @@ func (am *addressManager) getAddressSpace(id string) (*addressSpace, error) {
 
 // Sets a new or updates an existing address space.
 func (am *addressManager) setAddressSpace(as *addressSpace) error {
-	as1, ok := am.AddrSpaces[as.Id]
-	if !ok {
-		am.AddrSpaces[as.Id] = as
-	} else {
-		as1.merge(as)
+	return am.setAddressSpaces([]*addressSpace{as})
+}
+
+// Sets new or updates existing address spaces.
+// All address spaces are checked before any is set, so that an invalid one leaves the current state intact.
+func (am *addressManager) setAddressSpaces(addressSpaces []*addressSpace) error {
+	for i, as := range addressSpaces {
+		err := am.checkAddressSpaceOverlap(as)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range addressSpaces[i+1:] {
+			err = checkAddressSpacesOverlap(as, other)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	// Notify NetPlugin of external interfaces.
-	if am.netApi != nil {
-		for _, ap := range as.Pools {
-			am.netApi.AddExternalInterface(ap.IfName, ap.Subnet.String())
+	for _, as := range addressSpaces {
+		as1, ok := am.AddrSpaces[as.Id]
+		if !ok {
+			am.AddrSpaces[as.Id] = as
+		} else {
+			as1.merge(as)
+		}
+
+		// Notify NetPlugin of external interfaces.
+		if am.netApi != nil {
+			for _, ap := range as.Pools {
+				am.netApi.AddExternalInterface(ap.IfName, ap.Subnet.String())
+			}
 		}
 	}
 
@@ -191,6 +298,11 @@ func (as *addressSpace) merge(newas *addressSpace) {
 			pv.epoch = as.epoch
 		} else {
 			// This pool already exists.
+			// Take the latest gateway and DNS servers from the source.
+			ap.Gateway = pv.Gateway
+			ap.DnsServers = pv.DnsServers
+			ap.SourceExcludedRanges = pv.SourceExcludedRanges
+
 			// Compare address records one by one.
 			for ak, av := range pv.Addresses {
 				ar := ap.Addresses[ak]
@@ -218,6 +330,22 @@ func (as *addressSpace) merge(newas *addressSpace) {
 	// Cleanup stale pools and addresses from the old epoch.
 	// Those currently in use will be deleted after they are released.
 	for pk, pv := range as.Pools {
+		// Child pools are not reported by sources.
+		// Their addresses remain valid as long as the parent's do.
+		if pv.isChild() {
+			if parent := as.Pools[pv.ParentId]; parent != nil {
+				pv.Gateway = parent.Gateway
+				pv.DnsServers = parent.DnsServers
+				pv.SourceExcludedRanges = parent.SourceExcludedRanges
+
+				for ak, av := range pv.Addresses {
+					if par := parent.Addresses[ak]; par != nil && par.epoch == as.epoch {
+						av.epoch = as.epoch
+					}
+				}
+			}
+		}
+
 		if pv.epoch < as.epoch {
 			// This pool may have stale addresses.
 			for ak, av := range pv.Addresses {
@@ -291,7 +419,75 @@ func (as *addressSpace) requestPool(poolId string, subPoolId string, options map
 
 	log.Printf("[ipam] Requesting pool with poolId:%v options:%+v v6:%v.", poolId, options, v6)
 
-	if poolId != "" {
+	// Select the address allocation strategy for the pool.
+	strategy, hasStrategy := options[OptAllocStrategy]
+	if hasStrategy && !isValidAllocStrategy(strategy) {
+		log.Printf("[ipam] Invalid address allocation strategy %v.", strategy)
+		return nil, errInvalidAllocStrategy
+	}
+
+	// Select the pool selection policy for the request.
+	policy := options[OptPoolPolicy]
+	if policy == "" {
+		policy = PoolPolicyPriority
+	} else if !isValidPoolPolicy(policy) {
+		log.Printf("[ipam] Invalid pool selection policy %v.", policy)
+		return nil, errInvalidPoolPolicy
+	}
+
+	// Select the quarantine interval for released addresses, in seconds.
+	var quarantine time.Duration
+	interval, hasQuarantine := options[OptQuarantineInterval]
+	if hasQuarantine {
+		i, err := strconv.Atoi(interval)
+		if err != nil || i < 0 {
+			log.Printf("[ipam] Invalid address quarantine interval %v.", interval)
+			return nil, errInvalidQuarantine
+		}
+		quarantine = time.Duration(i) * time.Second
+	}
+
+	// Enable or disable probing for duplicate addresses before allocation.
+	var detectDuplicates bool
+	detect, hasDetect := options[OptDetectDuplicates]
+	if hasDetect {
+		detectDuplicates, err = strconv.ParseBool(detect)
+		if err != nil {
+			log.Printf("[ipam] Invalid duplicate address detection setting %v.", detect)
+			return nil, errInvalidDetectDuplicates
+		}
+	}
+
+	// Set the utilization percentage above which exhaustion events are reported.
+	var highWaterMark int
+	mark, hasMark := options[OptHighWaterMark]
+	if hasMark {
+		highWaterMark, err = strconv.Atoi(mark)
+		if err != nil || highWaterMark < 0 || highWaterMark > 100 {
+			log.Printf("[ipam] Invalid high-water mark %v.", mark)
+			return nil, errInvalidHighWaterMark
+		}
+	}
+
+	// Parse the addresses that must never be allocated from the pool.
+	var excludedRanges []net.IPNet
+	if excluded, ok := options[OptExcludedAddresses]; ok {
+		excludedRanges, err = parseAddressRanges(excluded)
+		if err != nil {
+			log.Printf("[ipam] Invalid excluded addresses %v, err:%v.", excluded, err)
+			return nil, err
+		}
+	}
+
+	if subPoolId != "" || options[OptChildPrefixLength] != "" {
+		// Delegate a child prefix from the requested parent pool.
+		parent := as.Pools[poolId]
+		if parent == nil {
+			err = errAddressPoolNotFound
+		} else {
+			ap, err = as.requestChildPool(parent, subPoolId, options)
+		}
+	} else if poolId != "" {
 		// Return the specific address pool requested.
 		// Note sharing of pools is allowed when specifically requested.
 		ap = as.Pools[poolId]
@@ -299,12 +495,30 @@ func (as *addressSpace) requestPool(poolId string, subPoolId string, options map
 			err = errAddressPoolNotFound
 		}
 	} else {
-		// Return any available address pool.
+		// Return any available address pool, as selected by the pool policy.
 		ifName := options[OptInterfaceName]
 
+		// Pinned pools must be on the requested interface.
+		if policy == PoolPolicyPinned && ifName == "" {
+			log.Printf("[ipam] Pool policy %v requires an interface.", policy)
+			return nil, errInvalidPoolPolicy
+		}
+
+		// Spread and fill policies balance addresses across interfaces.
+		var usage map[string]*interfaceUsage
+		if policy == PoolPolicySpread || policy == PoolPolicyFill {
+			usage = as.getInterfaceUsage(time.Now())
+		}
+
 		for _, pool := range as.Pools {
 			log.Printf("[ipam] Checking pool %v.", pool.Id)
 
+			// Skip child pools, which are handed out only by delegation.
+			if pool.isChild() {
+				log.Printf("[ipam] Pool is a child pool.")
+				continue
+			}
+
 			// Skip if pool is already in use.
 			if pool.isInUse() {
 				log.Printf("[ipam] Pool is in use.")
@@ -330,26 +544,53 @@ func (as *addressSpace) requestPool(poolId string, subPoolId string, options map
 				continue
 			}
 
-			// Prefer the pool with the highest priority.
-			if pool.Priority > ap.Priority {
-				log.Printf("[ipam] Pool is preferred because of priority.")
-				ap = pool
-			}
-
-			// Prefer the pool with the highest number of addresses.
-			if len(pool.Addresses) > len(ap.Addresses) {
-				log.Printf("[ipam] Pool is preferred because of capacity.")
+			if as.isPreferredPool(policy, pool, ap, usage) {
+				log.Printf("[ipam] Pool is preferred by policy %v.", policy)
 				ap = pool
 			}
 		}
 
 		if ap == nil {
 			err = errNoAvailableAddressPools
+		} else {
+			ap.PoolPolicy = policy
 		}
 	}
 
 	if ap != nil {
+		// Pool settings are configured by the first request for the pool.
+		// Requests that share a pool in use cannot change them.
+		if ap.isInUse() {
+			if hasStrategy && strategy != ap.getAllocStrategy() {
+				log.Printf("[ipam] Pool is in use with allocation strategy %v.", ap.getAllocStrategy())
+				err = errPoolSettingsConflict
+			}
+			if hasQuarantine && quarantine != ap.QuarantineInterval {
+				log.Printf("[ipam] Pool is in use with quarantine interval %v.", ap.QuarantineInterval)
+				err = errPoolSettingsConflict
+			}
+			if hasDetect && detectDuplicates != ap.DetectDuplicates {
+				log.Printf("[ipam] Pool is in use with duplicate address detection %v.", ap.DetectDuplicates)
+				err = errPoolSettingsConflict
+			}
+			if hasMark && highWaterMark != ap.HighWaterMark {
+				log.Printf("[ipam] Pool is in use with high-water mark %v.", ap.HighWaterMark)
+				err = errPoolSettingsConflict
+			}
+		} else {
+			ap.AllocStrategy = strategy
+			ap.QuarantineInterval = quarantine
+			ap.DetectDuplicates = detectDuplicates
+			ap.HighWaterMark = highWaterMark
+		}
+	}
+
+	if ap != nil && err == nil {
 		ap.RefCount++
+
+		if excludedRanges != nil {
+			ap.ExcludedRanges = excludedRanges
+		}
 	}
 
 	log.Printf("[ipam] Pool request completed with pool:%+v err:%v.", ap, err)
@@ -377,6 +618,10 @@ func (as *addressSpace) releasePool(poolId string) error {
 		return err
 	}
 
+	if ap.isChild() {
+		return as.releaseChildPool(ap)
+	}
+
 	ap.RefCount--
 
 	// Delete address pool if it is no longer available.
@@ -394,25 +639,41 @@ func (as *addressSpace) releasePool(poolId string) error {
 // Returns address pool information.
 func (ap *addressPool) getInfo() *AddressPoolInfo {
 	var available int
+	var capacity int
 	var unhealthyAddrs []net.IP
+	now := time.Now()
 
 	for _, ar := range ap.Addresses {
+		// Excluded addresses do not count towards capacity.
+		if ap.isExcluded(ar.Addr) {
+			continue
+		}
+
+		capacity++
 		if !ar.InUse {
 			available++
 		}
-		if ar.unhealthy {
+		if ar.unhealthy || ar.isDuplicate(now) {
 			unhealthyAddrs = append(unhealthyAddrs, ar.Addr)
 		}
 	}
 
+	dnsServers := ap.DnsServers
+	if len(dnsServers) == 0 {
+		dnsServers = []net.IP{dnsHostProxyAddress}
+	}
+
 	info := &AddressPoolInfo{
 		Subnet:         ap.Subnet,
+		IfName:         ap.IfName,
+		PoolPolicy:     ap.PoolPolicy,
 		Gateway:        ap.Gateway,
-		DnsServers:     []net.IP{dnsHostProxyAddress},
+		DnsServers:     dnsServers,
 		UnhealthyAddrs: unhealthyAddrs,
+		ExcludedRanges: append(append([]net.IPNet{}, ap.ExcludedRanges...), ap.SourceExcludedRanges...),
 		IsIPv6:         ap.IsIPv6,
 		Available:      available,
-		Capacity:       len(ap.Addresses),
+		Capacity:       capacity,
 	}
 
 	return info
@@ -423,6 +684,23 @@ func (ap *addressPool) isInUse() bool {
 	return ap.RefCount > 0
 }
 
+// Returns if an address is excluded from allocation.
+func (ap *addressPool) isExcluded(addr net.IP) bool {
+	if ap.isDelegated(addr) {
+		return true
+	}
+
+	for _, ranges := range [][]net.IPNet{ap.ExcludedRanges, ap.SourceExcludedRanges} {
+		for _, r := range ranges {
+			if r.Contains(addr) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // Creates a new addressRecord object.
 func (ap *addressPool) newAddressRecord(addr *net.IP) (*addressRecord, error) {
 	id := addr.String()
@@ -450,12 +728,30 @@ func (ap *addressPool) newAddressRecord(addr *net.IP) (*addressRecord, error) {
 func (ap *addressPool) requestAddress(address string, options map[string]string) (string, error) {
 	var ar *addressRecord
 	var addr *net.IPNet
+	var reservationTTL time.Duration
 	var err error
 	id := options[OptAddressID]
 
 	log.Printf("[ipam] Requesting address with address:%v options:%+v.", address, options)
 	defer func() { log.Printf("[ipam] Address request completed with address:%v err:%v.", addr, err) }()
 
+	// Keep the address reserved for the ID for a while after it is released.
+	if ttl, ok := options[OptReservationTTL]; ok && id != "" {
+		i, convErr := strconv.Atoi(ttl)
+		if convErr != nil || i < 0 {
+			err = errInvalidReservationTTL
+			return "", err
+		}
+		reservationTTL = time.Duration(i) * time.Second
+	}
+
+	// Reclaim the address automatically unless the lease is renewed in time,
+	// by requesting the address again with the same ID.
+	leaseDuration, err := parseLeaseDuration(options)
+	if err != nil {
+		return "", err
+	}
+
 	if address != "" {
 		// Return the specific address requested.
 		ar = ap.Addresses[address]
@@ -463,12 +759,19 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 			err = errAddressNotFound
 			return "", err
 		}
+		if ap.isExcluded(ar.Addr) {
+			err = errAddressExcluded
+			return "", err
+		}
 		if ar.InUse {
 			// Return the same address if IDs match.
 			if id == "" || id != ar.ID {
 				err = errAddressInUse
 				return "", err
 			}
+		} else if ar.isReserved(time.Now()) && id != ar.ID {
+			err = errAddressReserved
+			return "", err
 		}
 	} else if options[OptAddressType] == OptAddressTypeGateway {
 		// Return the pre-assigned gateway address.
@@ -481,31 +784,54 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 		ar = ap.addrsByID[id]
 	}
 
-	// If no address was found, return any available address.
+	// If no address was found, return an available address.
 	if ar == nil {
-		for _, ar = range ap.Addresses {
-			if !ar.InUse && ar.ID == "" {
-				break
-			}
-			ar = nil
-		}
-
+		ar = ap.getAvailableAddress()
 		if ar == nil {
 			return "", errNoAvailableAddresses
 		}
+
+		ap.LastAddr = ar.Addr
+	}
+
+	// Requesting an in-use address again with its ID renews its lease, and is not a new allocation.
+	renewal := ar.InUse && id != "" && ar.ID == id
+
+	// Drop any expired reservation held by a different ID.
+	if ar.ID != "" && ar.ID != id {
+		delete(ap.addrsByID, ar.ID)
+		ar.ID = ""
 	}
 
 	if id != "" {
 		ap.addrsByID[id] = ar
 		ar.ID = id
-	} else {
-		ar.InUse = true
+	}
+
+	ar.InUse = true
+	ar.Owner = options[OptAddressOwner]
+	ar.ReservationTTL = reservationTTL
+	ar.ReservedUntil = time.Time{}
+	ar.LeaseDuration = leaseDuration
+	ar.renewLease(time.Now())
+
+	// The address is no longer quarantined once it is handed out.
+	ar.QuarantineUntil = time.Time{}
+
+	if options[OptAddressType] != OptAddressTypeGateway && !renewal {
+		ap.Allocations.add(time.Now())
 	}
 
 	// Return address in CIDR notation.
+	// Addresses in child pools are on the parent's link, so they take the parent's mask.
+	mask := ap.Subnet.Mask
+	if parent := ap.as.Pools[ap.ParentId]; ap.isChild() && parent != nil {
+		mask = parent.Subnet.Mask
+	}
+
 	addr = &net.IPNet{
 		IP:   ar.Addr,
-		Mask: ap.Subnet.Mask,
+		Mask: mask,
 	}
 
 	return addr.String(), nil
@@ -552,8 +878,21 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 	}
 
 	ar.InUse = false
+	ar.Owner = ""
+	ar.ReleaseTime = time.Now()
+	ar.LeaseDuration = 0
+	ar.LeaseExpiry = time.Time{}
+	ap.Releases.add(ar.ReleaseTime)
 
-	if id != "" && ar.ID == id {
+	// Quarantine the address so that it is not reused right away.
+	if ap.QuarantineInterval > 0 {
+		ar.QuarantineUntil = ar.ReleaseTime.Add(ap.QuarantineInterval)
+	}
+
+	if ar.ReservationTTL > 0 {
+		// Keep the address reserved for its ID until the reservation expires.
+		ar.ReservedUntil = ar.ReleaseTime.Add(ar.ReservationTTL)
+	} else if ar.ID != "" {
 		delete(ap.addrsByID, ar.ID)
 		ar.ID = ""
 	}
@@ -566,3 +905,42 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 
 	return nil
 }
+
+// Releases in-use addresses whose owners are not in the set of live IDs.
+// Addresses are owned by their ID, unless they were requested with a separate owner,
+// such as the container holding an address reserved for a pod.
+// Addresses without an ID cannot be attributed to an owner and are left untouched.
+func (ap *addressPool) releaseStaleAddresses(liveIds map[string]bool) []*ReclaimedAddressInfo {
+	var reclaimed []*ReclaimedAddressInfo
+
+	for _, ar := range ap.Addresses {
+		owner := ar.Owner
+		if owner == "" {
+			owner = ar.ID
+		}
+
+		if !ar.InUse || ar.ID == "" || liveIds[owner] {
+			continue
+		}
+
+		info := &ReclaimedAddressInfo{
+			AsId:    ap.as.Id,
+			PoolId:  ap.Id,
+			Address: ar.Addr,
+			ID:      ar.ID,
+			Owner:   ar.Owner,
+		}
+
+		log.Printf("[ipam] Releasing stale address %v with ID %v owner %v.", ar.Addr, ar.ID, ar.Owner)
+
+		err := ap.releaseAddress(ar.Addr.String(), map[string]string{OptAddressID: ar.ID})
+		if err != nil {
+			log.Printf("[ipam] Failed to release stale address %v, err:%v.", ar.Addr, err)
+			continue
+		}
+
+		reclaimed = append(reclaimed, info)
+	}
+
+	return reclaimed
+}
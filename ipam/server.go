@@ -0,0 +1,348 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Tag used in request and response logs.
+	serverLogTag = "azure-vnet-ipamd"
+
+	// AddressManager API paths served by the IPAM daemon.
+	GetDefaultAddressSpacesPath = "/ipam/getdefaultaddressspaces"
+	RequestPoolPath             = "/ipam/requestpool"
+	ReleasePoolPath             = "/ipam/releasepool"
+	GetPoolInfoPath             = "/ipam/getpoolinfo"
+	RequestAddressPath          = "/ipam/requestaddress"
+	ReleaseAddressPath          = "/ipam/releaseaddress"
+	ReleaseStaleAddressesPath   = "/ipam/releasestaleaddresses"
+	GetMetricsPath              = "/ipam/getmetrics"
+)
+
+// Request and response payloads of the AddressManager API.
+type getDefaultAddressSpacesRequest struct {
+}
+
+type getDefaultAddressSpacesResponse struct {
+	Err                       string
+	ErrCode                   int
+	LocalDefaultAddressSpace  string
+	GlobalDefaultAddressSpace string
+}
+
+type requestPoolRequest struct {
+	AsId      string
+	PoolId    string
+	SubPoolId string
+	Options   map[string]string
+	V6        bool
+}
+
+type requestPoolResponse struct {
+	Err     string
+	ErrCode int
+	PoolId  string
+	Subnet  string
+}
+
+type releasePoolRequest struct {
+	AsId   string
+	PoolId string
+}
+
+type releasePoolResponse struct {
+	Err     string
+	ErrCode int
+}
+
+type getPoolInfoRequest struct {
+	AsId   string
+	PoolId string
+}
+
+type getPoolInfoResponse struct {
+	Err     string
+	ErrCode int
+	Info    *AddressPoolInfo
+}
+
+type requestAddressRequest struct {
+	AsId    string
+	PoolId  string
+	Address string
+	Options map[string]string
+}
+
+type requestAddressResponse struct {
+	Err     string
+	ErrCode int
+	Address string
+}
+
+type releaseAddressRequest struct {
+	AsId    string
+	PoolId  string
+	Address string
+	Options map[string]string
+}
+
+type releaseAddressResponse struct {
+	Err     string
+	ErrCode int
+}
+
+type releaseStaleAddressesRequest struct {
+	AsId    string
+	LiveIds []string
+}
+
+type releaseStaleAddressesResponse struct {
+	Err       string
+	ErrCode   int
+	Reclaimed []*ReclaimedAddressInfo
+}
+
+type getMetricsRequest struct {
+	AsId string
+}
+
+type getMetricsResponse struct {
+	Err     string
+	ErrCode int
+	Metrics []*AddressSpaceMetrics
+}
+
+// AddressManagerServer serves an in-memory AddressManager to IPAM clients over a listener.
+type AddressManagerServer struct {
+	am       AddressManager
+	listener *common.Listener
+}
+
+// NewAddressManagerServer creates a new AddressManagerServer and registers its handlers on the listener.
+func NewAddressManagerServer(am AddressManager, listener *common.Listener) (*AddressManagerServer, error) {
+	server := &AddressManagerServer{
+		am:       am,
+		listener: listener,
+	}
+
+	listener.AddHandler(GetDefaultAddressSpacesPath, server.getDefaultAddressSpaces)
+	listener.AddHandler(RequestPoolPath, server.requestPool)
+	listener.AddHandler(ReleasePoolPath, server.releasePool)
+	listener.AddHandler(GetPoolInfoPath, server.getPoolInfo)
+	listener.AddHandler(RequestAddressPath, server.requestAddress)
+	listener.AddHandler(ReleaseAddressPath, server.releaseAddress)
+	listener.AddHandler(ReleaseStaleAddressesPath, server.releaseStaleAddresses)
+	listener.AddHandler(GetMetricsPath, server.getMetrics)
+
+	return server, nil
+}
+
+// Errors sent to clients as codes, so that clients return the same errors as the address manager.
+// A code is the index of its error in the list plus one. Append new errors at the end only.
+var responseErrors = []error{
+	errInvalidAddressSpace,
+	errInvalidPoolId,
+	errInvalidAddress,
+	errInvalidScope,
+	errInvalidConfiguration,
+	errAddressPoolExists,
+	errAddressPoolNotFound,
+	errAddressPoolInUse,
+	errAddressPoolNotInUse,
+	errNoAvailableAddressPools,
+	errAddressExists,
+	errAddressNotFound,
+	errAddressInUse,
+	errAddressNotInUse,
+	errNoAvailableAddresses,
+	errInvalidAllocStrategy,
+	errPoolSettingsConflict,
+	errInvalidQuarantine,
+	errAddressExcluded,
+	errAddressReserved,
+	errInvalidReservationTTL,
+	errInvalidDetectDuplicates,
+	errDuplicateAddresses,
+	errInvalidChildPrefix,
+	errChildPrefixUnavailable,
+	errAddressPoolHasAddresses,
+	errInvalidHighWaterMark,
+	errInvalidLeaseDuration,
+	errInvalidPoolPolicy,
+}
+
+// Returns the error message sent in responses, or the empty string on success.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// Returns the error code sent in responses, or zero for errors without a code.
+func errorCode(err error) int {
+	for i, e := range responseErrors {
+		if err == e {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// Handles GetDefaultAddressSpaces requests.
+func (server *AddressManagerServer) getDefaultAddressSpaces(w http.ResponseWriter, r *http.Request) {
+	var req getDefaultAddressSpacesRequest
+
+	log.Request(serverLogTag, &req, nil)
+
+	resp := getDefaultAddressSpacesResponse{}
+	resp.LocalDefaultAddressSpace, resp.GlobalDefaultAddressSpace = server.am.GetDefaultAddressSpaces()
+
+	err := server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles RequestPool requests.
+func (server *AddressManagerServer) requestPool(w http.ResponseWriter, r *http.Request) {
+	var req requestPoolRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := requestPoolResponse{}
+	resp.PoolId, resp.Subnet, err = server.am.RequestPool(req.AsId, req.PoolId, req.SubPoolId, req.Options, req.V6)
+	resp.Err = errorString(err)
+	resp.ErrCode = errorCode(err)
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles ReleasePool requests.
+func (server *AddressManagerServer) releasePool(w http.ResponseWriter, r *http.Request) {
+	var req releasePoolRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	err = server.am.ReleasePool(req.AsId, req.PoolId)
+	resp := releasePoolResponse{Err: errorString(err), ErrCode: errorCode(err)}
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles GetPoolInfo requests.
+func (server *AddressManagerServer) getPoolInfo(w http.ResponseWriter, r *http.Request) {
+	var req getPoolInfoRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := getPoolInfoResponse{}
+	resp.Info, err = server.am.GetPoolInfo(req.AsId, req.PoolId)
+	resp.Err = errorString(err)
+	resp.ErrCode = errorCode(err)
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles RequestAddress requests.
+func (server *AddressManagerServer) requestAddress(w http.ResponseWriter, r *http.Request) {
+	var req requestAddressRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := requestAddressResponse{}
+	resp.Address, err = server.am.RequestAddress(req.AsId, req.PoolId, req.Address, req.Options)
+	resp.Err = errorString(err)
+	resp.ErrCode = errorCode(err)
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles ReleaseAddress requests.
+func (server *AddressManagerServer) releaseAddress(w http.ResponseWriter, r *http.Request) {
+	var req releaseAddressRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	err = server.am.ReleaseAddress(req.AsId, req.PoolId, req.Address, req.Options)
+	resp := releaseAddressResponse{Err: errorString(err), ErrCode: errorCode(err)}
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles ReleaseStaleAddresses requests.
+func (server *AddressManagerServer) releaseStaleAddresses(w http.ResponseWriter, r *http.Request) {
+	var req releaseStaleAddressesRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := releaseStaleAddressesResponse{}
+	resp.Reclaimed, err = server.am.ReleaseStaleAddresses(req.AsId, req.LiveIds)
+	resp.Err = errorString(err)
+	resp.ErrCode = errorCode(err)
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
+
+// Handles GetMetrics requests.
+func (server *AddressManagerServer) getMetrics(w http.ResponseWriter, r *http.Request) {
+	var req getMetricsRequest
+
+	err := server.listener.Decode(w, r, &req)
+	log.Request(serverLogTag, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := getMetricsResponse{}
+	resp.Metrics, err = server.am.GetMetrics(req.AsId)
+	resp.Err = errorString(err)
+	resp.ErrCode = errorCode(err)
+
+	err = server.listener.Encode(w, &resp)
+
+	log.Response(serverLogTag, &resp, 0, "Success", err)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+)
+
+// Tests address manager requests are served to clients over a unix socket.
+func TestAddressManagerServer(t *testing.T) {
+	defer setHostSubnets()()
+
+	// Start with the test address space.
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	dir, err := ioutil.TempDir("", "ipamd")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir, err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "ipamd.sock")
+
+	// Clients fail to connect until the daemon is running.
+	_, err = NewAddressManagerClient(socketPath)
+	if err == nil {
+		t.Fatalf("NewAddressManagerClient succeeded without a daemon.")
+	}
+
+	// Serve the address manager on the socket.
+	listener, err := common.NewListener(&url.URL{Scheme: "unix", Path: socketPath})
+	if err != nil {
+		t.Fatalf("NewListener failed, err:%v", err)
+	}
+
+	_, err = NewAddressManagerServer(am, listener)
+	if err != nil {
+		t.Fatalf("NewAddressManagerServer failed, err:%v", err)
+	}
+
+	err = listener.Start(make(chan error, 1))
+	if err != nil {
+		t.Fatalf("Failed to start listener, err:%v", err)
+	}
+	defer listener.Stop()
+
+	client, err := NewAddressManagerClient(socketPath)
+	if err != nil {
+		t.Fatalf("NewAddressManagerClient failed, err:%v", err)
+	}
+
+	localAs, _ := client.GetDefaultAddressSpaces()
+	if localAs != LocalDefaultAddressSpaceId {
+		t.Errorf("GetDefaultAddressSpaces returned %v", localAs)
+	}
+
+	// Request a pool and an address through the client.
+	poolId, subnet, err := client.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil || poolId != subnet1.String() || subnet != subnet1.String() {
+		t.Fatalf("RequestPool failed, poolId:%v subnet:%v err:%v", poolId, subnet, err)
+	}
+
+	address, err := client.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "id1"})
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	// The allocation is visible in the daemon's address manager.
+	info, err := client.GetPoolInfo(LocalDefaultAddressSpaceId, poolId)
+	if err != nil {
+		t.Fatalf("GetPoolInfo failed, err:%v", err)
+	}
+
+	if info.Subnet.String() != subnet1.String() || info.Capacity != 2 || info.Available != 1 {
+		t.Errorf("GetPoolInfo returned incorrect info %+v", info)
+	}
+
+	// Errors are returned to the client as the address manager's own errors.
+	_, err = client.GetPoolInfo(LocalDefaultAddressSpaceId, subnet3.String())
+	if err != errInvalidPoolId {
+		t.Errorf("GetPoolInfo of unknown pool returned err:%v", err)
+	}
+
+	_, _, err = client.RequestPool(LocalDefaultAddressSpaceId, subnet3.String(), "", nil, false)
+	if err != errAddressPoolNotFound {
+		t.Errorf("RequestPool of unknown pool returned err:%v", err)
+	}
+
+	// Exhaustion events are reported by the daemon, not by its clients.
+	err = client.SetExhaustionHandler(func(event *ExhaustionEvent) {})
+	if err != errExhaustionHandlerNotSupported {
+		t.Errorf("SetExhaustionHandler returned err:%v", err)
+	}
+
+	err = client.ReleasePool(LocalDefaultAddressSpaceId, poolId)
+	if err != nil {
+		t.Errorf("ReleasePool failed, err:%v", err)
+	}
+
+	// Release the address using its ID.
+	err = client.ReleaseAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "id1"})
+	if err != nil {
+		t.Errorf("ReleaseAddress failed for address %v, err:%v", address, err)
+	}
+
+	metrics, err := client.GetMetrics(LocalDefaultAddressSpaceId)
+	if err != nil || len(metrics) != 1 || metrics[0].InUse != 0 {
+		t.Errorf("GetMetrics returned metrics:%+v err:%v", metrics, err)
+	}
+}
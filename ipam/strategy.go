@@ -0,0 +1,130 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Returns whether the given address allocation strategy is supported.
+func isValidAllocStrategy(strategy string) bool {
+	switch strategy {
+	case AllocStrategyAny, AllocStrategyLowestFree, AllocStrategyRoundRobin, AllocStrategyLeastRecentlyReleased:
+		return true
+	}
+
+	return false
+}
+
+// Returns whether an address record can be handed out to any ID.
+func (ar *addressRecord) isAvailable(now time.Time) bool {
+	return !ar.InUse && !ar.tentative && (ar.ID == "" || !ar.isReserved(now))
+}
+
+// Returns whether a released address record is still reserved for its ID.
+func (ar *addressRecord) isReserved(now time.Time) bool {
+	return ar.ID != "" && now.Before(ar.ReservedUntil)
+}
+
+// Returns whether an address record is in its post-release quarantine window.
+func (ar *addressRecord) isQuarantined(now time.Time) bool {
+	return now.Before(ar.QuarantineUntil)
+}
+
+// Compares two IP addresses in network byte order.
+func compareAddresses(a net.IP, b net.IP) int {
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+// Returns an available address record selected by the address space's allocation strategy.
+// Quarantined addresses are returned only if the pool is otherwise exhausted.
+func (ap *addressPool) getAvailableAddress() *addressRecord {
+	now := time.Now()
+
+	ar := ap.selectAddress(func(ar *addressRecord) bool {
+		return ar.isAvailable(now) && !ap.isExcluded(ar.Addr) && !ar.isDuplicate(now) && !ar.isQuarantined(now)
+	})
+
+	if ar == nil {
+		ar = ap.selectAddress(func(ar *addressRecord) bool {
+			return ar.isAvailable(now) && !ap.isExcluded(ar.Addr) && !ar.isDuplicate(now)
+		})
+
+		if ar != nil {
+			log.Printf("[ipam] Pool %v exhausted, reusing quarantined address %v.", ap.Id, ar.Addr)
+		}
+	}
+
+	return ar
+}
+
+// Returns the address allocation strategy of the pool.
+func (ap *addressPool) getAllocStrategy() string {
+	if ap.AllocStrategy == "" {
+		return AllocStrategyAny
+	}
+
+	return ap.AllocStrategy
+}
+
+// Returns the address record matching the filter that is preferred by the allocation strategy.
+func (ap *addressPool) selectAddress(filter func(*addressRecord) bool) *addressRecord {
+	strategy := ap.getAllocStrategy()
+
+	var selected *addressRecord
+
+	for _, ar := range ap.Addresses {
+		if !filter(ar) {
+			continue
+		}
+
+		if selected == nil {
+			selected = ar
+			if strategy == AllocStrategyAny {
+				break
+			}
+			continue
+		}
+
+		if ap.isPreferredAddress(strategy, ar, selected) {
+			selected = ar
+		}
+	}
+
+	return selected
+}
+
+// Returns whether address record ar is preferred over the current selection under the given strategy.
+func (ap *addressPool) isPreferredAddress(strategy string, ar *addressRecord, selected *addressRecord) bool {
+	lower := compareAddresses(ar.Addr, selected.Addr) < 0
+
+	switch strategy {
+	case AllocStrategyRoundRobin:
+		// Prefer the lowest address after the last allocation, then wrap around to the lowest address.
+		if ap.LastAddr == nil {
+			return lower
+		}
+
+		arAfter := compareAddresses(ar.Addr, ap.LastAddr) > 0
+		selectedAfter := compareAddresses(selected.Addr, ap.LastAddr) > 0
+		if arAfter != selectedAfter {
+			return arAfter
+		}
+		return lower
+
+	case AllocStrategyLeastRecentlyReleased:
+		// Prefer the address released the longest time ago. Never-released addresses come first.
+		if !ar.ReleaseTime.Equal(selected.ReleaseTime) {
+			return ar.ReleaseTime.Before(selected.ReleaseTime)
+		}
+		return lower
+
+	default:
+		return lower
+	}
+}
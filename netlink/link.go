@@ -8,6 +8,7 @@ package netlink
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 	"golang.org/x/sys/unix"
@@ -15,10 +16,12 @@ import (
 
 // Link types.
 const (
-	LINK_TYPE_BRIDGE = "bridge"
-	LINK_TYPE_VETH   = "veth"
-	LINK_TYPE_IPVLAN = "ipvlan"
-	LINK_TYPE_DUMMY  = "dummy"
+	LINK_TYPE_BRIDGE  = "bridge"
+	LINK_TYPE_VETH    = "veth"
+	LINK_TYPE_IPVLAN  = "ipvlan"
+	LINK_TYPE_MACVLAN = "macvlan"
+	LINK_TYPE_VXLAN   = "vxlan"
+	LINK_TYPE_DUMMY   = "dummy"
 )
 
 // IPVLAN link attributes.
@@ -31,6 +34,16 @@ const (
 	IPVLAN_MODE_MAX
 )
 
+// MACVLAN link attributes.
+type MacvlanMode uint32
+
+const (
+	MACVLAN_MODE_PRIVATE  MacvlanMode = 1
+	MACVLAN_MODE_VEPA     MacvlanMode = 2
+	MACVLAN_MODE_BRIDGE   MacvlanMode = 4
+	MACVLAN_MODE_PASSTHRU MacvlanMode = 8
+)
+
 const (
 	ADD = iota
 	REMOVE
@@ -72,6 +85,22 @@ type IPVlanLink struct {
 	Mode IPVlanMode
 }
 
+// MacvlanLink represents a MACVLAN network interface.
+type MacvlanLink struct {
+	LinkInfo
+	Mode MacvlanMode
+}
+
+// VxlanLink represents a VXLAN tunnel endpoint network interface.
+// ParentIndex selects the interface that carries the encapsulated packets.
+type VxlanLink struct {
+	LinkInfo
+	VNI      uint32
+	LocalIP  net.IP
+	Port     uint16
+	Learning bool
+}
+
 // DummyLink represents a dummy network interface.
 type DummyLink struct {
 	LinkInfo
@@ -134,6 +163,11 @@ func AddLink(link Link) error {
 		attrPeer := newAttribute(VETH_INFO_PEER, nil)
 		attrPeer.addNested(newIfInfoMsg())
 		attrPeer.addNested(newAttributeStringZ(unix.IFLA_IFNAME, veth.PeerName))
+
+		// The peer does not inherit the MTU of the link.
+		if info.MTU > 0 {
+			attrPeer.addNested(newAttributeUint32(unix.IFLA_MTU, uint32(info.MTU)))
+		}
 		attrData.addNested(attrPeer)
 
 		attrLinkInfo.addNested(attrData)
@@ -143,6 +177,40 @@ func AddLink(link Link) error {
 		attrData := newAttribute(IFLA_INFO_DATA, nil)
 		attrData.addNested(newAttributeUint16(IFLA_IPVLAN_MODE, uint16(ipvlan.Mode)))
 
+		attrLinkInfo.addNested(attrData)
+
+	} else if macvlan, ok := link.(*MacvlanLink); ok {
+		// Set MACVLAN attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_MACVLAN_MODE, uint32(macvlan.Mode)))
+
+		attrLinkInfo.addNested(attrData)
+
+	} else if vxlan, ok := link.(*VxlanLink); ok {
+		// Set VXLAN attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_VXLAN_ID, vxlan.VNI))
+
+		if info.ParentIndex != 0 {
+			attrData.addNested(newAttributeUint32(IFLA_VXLAN_LINK, uint32(info.ParentIndex)))
+		}
+
+		if vxlan.LocalIP.To4() != nil {
+			attrData.addNested(newAttributeIpAddress(IFLA_VXLAN_LOCAL, vxlan.LocalIP))
+		} else if vxlan.LocalIP != nil {
+			attrData.addNested(newAttributeIpAddress(IFLA_VXLAN_LOCAL6, vxlan.LocalIP))
+		}
+
+		var learning uint8
+		if vxlan.Learning {
+			learning = 1
+		}
+		attrData.addNested(newAttributeUint8(IFLA_VXLAN_LEARNING, learning))
+
+		if vxlan.Port != 0 {
+			attrData.addNested(newAttributeUint16BigEndian(IFLA_VXLAN_PORT, vxlan.Port))
+		}
+
 		attrLinkInfo.addNested(attrData)
 	}
 
@@ -178,6 +246,70 @@ func DeleteLink(name string) error {
 	return s.sendAndWaitForAck(req)
 }
 
+// GetLink returns the type, MTU and parent interface of a network interface.
+func GetLink(name string) (*LinkInfo, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETLINK, 0)
+
+	ifInfo := newIfInfoMsg()
+	ifInfo.Index = int32(iface.Index)
+	req.addPayload(ifInfo)
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("No link info for interface %v", name)
+	}
+
+	info := &LinkInfo{
+		Name:  iface.Name,
+		Flags: iface.Flags,
+	}
+
+	for _, attr := range msgs[0].getAttributes(nil) {
+		switch attr.Type {
+		case unix.IFLA_MTU:
+			info.MTU = uint(encoder.Uint32(attr.value[0:4]))
+		case unix.IFLA_LINK:
+			info.ParentIndex = int(encoder.Uint32(attr.value[0:4]))
+		case unix.IFLA_LINKINFO:
+			// Nested attributes, the link type is in IFLA_INFO_KIND.
+			b := attr.value
+			for len(b) >= unix.SizeofRtAttr {
+				attrLen := int(encoder.Uint16(b[0:2]))
+				attrType := int(encoder.Uint16(b[2:4]))
+				if attrLen < unix.SizeofRtAttr || attrLen > len(b) {
+					break
+				}
+
+				if attrType == IFLA_INFO_KIND {
+					info.Type = strings.TrimRight(string(b[unix.SizeofRtAttr:attrLen]), "\x00")
+				}
+
+				next := rtaAlignOf(attrLen)
+				if next > len(b) {
+					break
+				}
+				b = b[next:]
+			}
+		}
+	}
+
+	return info, nil
+}
+
 // SetLinkName sets the name of a network interface.
 func SetLinkName(name string, newName string) error {
 	s, err := getSocket()
@@ -325,6 +457,32 @@ func SetLinkAddress(ifName string, hwAddress net.HardwareAddr) error {
 	return s.sendAndWaitForAck(req)
 }
 
+// SetLinkMTU sets the maximum transmission unit of a network interface.
+func SetLinkMTU(ifName string, mtu int) error {
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_SETLINK, unix.NLM_F_ACK)
+
+	ifInfo := newIfInfoMsg()
+	ifInfo.Type = unix.RTM_SETLINK
+	ifInfo.Index = int32(iface.Index)
+	ifInfo.Flags = unix.NLM_F_REQUEST
+	ifInfo.Change = DEFAULT_CHANGE
+	req.addPayload(ifInfo)
+
+	req.addPayload(newAttributeUint32(unix.IFLA_MTU, uint32(mtu)))
+
+	return s.sendAndWaitForAck(req)
+}
+
 // SetLinkPromisc sets the promiscuous mode of a network interface.
 func SetLinkPromisc(ifName string, on bool) error {
 	s, err := getSocket()
@@ -428,3 +586,45 @@ func AddOrRemoveStaticArp(mode int, name string, ipaddr net.IP, mac net.Hardware
 
 	return s.sendAndWaitForAck(req)
 }
+
+// AddOrRemoveFdbEntry sets/removes a static forwarding database entry based on mode.
+// The entry sends frames for the MAC address to the remote tunnel endpoint at dst.
+// An all-zeros MAC address adds a default destination for broadcast and unknown unicast frames.
+func AddOrRemoveFdbEntry(mode int, name string, mac net.HardwareAddr, dst net.IP) error {
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	var req *message
+	if mode == ADD {
+		req = newRequest(unix.RTM_NEWNEIGH, unix.NLM_F_CREATE|unix.NLM_F_APPEND|unix.NLM_F_ACK)
+	} else {
+		req = newRequest(unix.RTM_DELNEIGH, unix.NLM_F_ACK)
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+
+	msg := neighMsg{
+		Family: uint8(unix.AF_BRIDGE),
+		Index:  uint32(iface.Index),
+		State:  uint16(NUD_PERMANENT),
+		Flags:  uint8(NTF_SELF),
+	}
+	req.addPayload(&msg)
+
+	hwData := newRtAttr(NDA_LLADDR, []byte(mac))
+	req.addPayload(hwData)
+
+	ipData := dst.To4()
+	if ipData == nil {
+		ipData = dst.To16()
+	}
+	dstData := newRtAttr(NDA_DST, ipData)
+	req.addPayload(dstData)
+
+	return s.sendAndWaitForAck(req)
+}
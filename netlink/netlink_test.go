@@ -97,6 +97,35 @@ func TestAddDeleteVEth(t *testing.T) {
 	}
 }
 
+// TestAddVEthWithMTU tests that both ends of a VEth pair get the MTU of the link.
+func TestAddVEthWithMTU(t *testing.T) {
+	link := VEthLink{
+		LinkInfo: LinkInfo{
+			Type: LINK_TYPE_VETH,
+			Name: ifName,
+			MTU:  1400,
+		},
+		PeerName: ifName2,
+	}
+
+	err := AddLink(&link)
+	if err != nil {
+		t.Errorf("AddLink failed: %+v", err)
+	}
+
+	for _, name := range []string{ifName, ifName2} {
+		iface, err := net.InterfaceByName(name)
+		if err != nil || iface.MTU != 1400 {
+			t.Errorf("Interface %v has MTU %+v, err:%v", name, iface, err)
+		}
+	}
+
+	err = DeleteLink(ifName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %+v", err)
+	}
+}
+
 // TestAddDeleteIPVlan tests adding and deleting an IPVLAN interface.
 func TestAddDeleteIPVlan(t *testing.T) {
 	dummy, err := addDummyInterface(dummyName)
@@ -118,6 +147,58 @@ func TestAddDeleteIPVlan(t *testing.T) {
 		t.Errorf("AddLink failed: %+v", err)
 	}
 
+	info, err := GetLink(ifName)
+	if err != nil || info.Type != LINK_TYPE_IPVLAN || info.ParentIndex != dummy.Index {
+		t.Errorf("GetLink returned %+v, err:%v", info, err)
+	}
+
+	err = DeleteLink(ifName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %+v", err)
+	}
+
+	_, err = net.InterfaceByName(ifName)
+	if err == nil {
+		t.Errorf("Interface not deleted")
+	}
+
+	err = DeleteLink(dummyName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %v", err)
+	}
+}
+
+// TestAddDeleteMacvlan tests adding and deleting a MACVLAN interface.
+func TestAddDeleteMacvlan(t *testing.T) {
+	dummy, err := addDummyInterface(dummyName)
+	if err != nil {
+		t.Errorf("addDummyInterface failed: %v", err)
+	}
+
+	link := MacvlanLink{
+		LinkInfo: LinkInfo{
+			Type:        LINK_TYPE_MACVLAN,
+			Name:        ifName,
+			ParentIndex: dummy.Index,
+		},
+		Mode: MACVLAN_MODE_BRIDGE,
+	}
+
+	err = AddLink(&link)
+	if err != nil {
+		t.Errorf("AddLink failed: %+v", err)
+	}
+
+	macvlan, err := net.InterfaceByName(ifName)
+	if err != nil || macvlan.HardwareAddr.String() == dummy.HardwareAddr.String() {
+		t.Errorf("MACVLAN interface %+v does not have its own MAC address, err:%v", macvlan, err)
+	}
+
+	info, err := GetLink(ifName)
+	if err != nil || info.Type != LINK_TYPE_MACVLAN || info.ParentIndex != dummy.Index {
+		t.Errorf("GetLink returned %+v, err:%v", info, err)
+	}
+
 	err = DeleteLink(ifName)
 	if err != nil {
 		t.Errorf("DeleteLink failed: %+v", err)
@@ -134,6 +215,52 @@ func TestAddDeleteIPVlan(t *testing.T) {
 	}
 }
 
+// TestAddDeleteVxlan tests adding and deleting a VXLAN interface and its forwarding entries.
+func TestAddDeleteVxlan(t *testing.T) {
+	dummy, err := addDummyInterface(dummyName)
+	if err != nil {
+		t.Errorf("addDummyInterface failed: %v", err)
+	}
+
+	link := VxlanLink{
+		LinkInfo: LinkInfo{
+			Type:        LINK_TYPE_VXLAN,
+			Name:        ifName,
+			ParentIndex: dummy.Index,
+		},
+		VNI:  4096,
+		Port: 4789,
+	}
+
+	err = AddLink(&link)
+	if err != nil {
+		t.Errorf("AddLink failed: %+v", err)
+	}
+
+	mac, _ := net.ParseMAC("0a:58:0a:00:00:05")
+	dst := net.ParseIP("10.0.0.5")
+
+	err = AddOrRemoveFdbEntry(ADD, ifName, mac, dst)
+	if err != nil {
+		t.Errorf("AddOrRemoveFdbEntry failed to add entry: %+v", err)
+	}
+
+	err = AddOrRemoveFdbEntry(REMOVE, ifName, mac, dst)
+	if err != nil {
+		t.Errorf("AddOrRemoveFdbEntry failed to remove entry: %+v", err)
+	}
+
+	err = DeleteLink(ifName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %+v", err)
+	}
+
+	err = DeleteLink(dummyName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %v", err)
+	}
+}
+
 // TestSetLinkState tests setting the operational state of a network interface.
 func TestSetLinkState(t *testing.T) {
 	_, err := addDummyInterface(ifName)
@@ -167,6 +294,29 @@ func TestSetLinkState(t *testing.T) {
 	}
 }
 
+// TestSetLinkMTU tests setting the MTU of a network interface.
+func TestSetLinkMTU(t *testing.T) {
+	_, err := addDummyInterface(ifName)
+	if err != nil {
+		t.Errorf("addDummyInterface failed: %v", err)
+	}
+
+	err = SetLinkMTU(ifName, 9000)
+	if err != nil {
+		t.Errorf("SetLinkMTU failed: %+v", err)
+	}
+
+	dummy, err := net.InterfaceByName(ifName)
+	if err != nil || dummy.MTU != 9000 {
+		t.Errorf("Interface MTU not set")
+	}
+
+	err = DeleteLink(ifName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %+v", err)
+	}
+}
+
 // TestSetLinkPromisc tests setting the promiscuous mode of a network interface.
 func TestSetLinkPromisc(t *testing.T) {
 	_, err := addDummyInterface(ifName)
@@ -19,4 +19,6 @@ var (
 	errMultipleEndpointsFound = fmt.Errorf("Multiple endpoints found")
 	errEndpointInUse          = fmt.Errorf("Endpoint is already joined to a sandbox")
 	errEndpointNotInUse       = fmt.Errorf("Endpoint is not joined to a sandbox")
+	errVxlanConfigInvalid     = fmt.Errorf("VXLAN overlay configuration is invalid")
+	errVxlanIDInUse           = fmt.Errorf("VXLAN ID is used by another network")
 )
@@ -39,7 +39,7 @@ func NewLinuxBridgeEndpointClient(
 }
 
 func (client *LinuxBridgeEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
-	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName); err != nil {
+	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
 		return err
 	}
 
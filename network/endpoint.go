@@ -22,6 +22,7 @@ type endpoint struct {
 	SandboxKey               string
 	IfName                   string
 	HostIfName               string
+	TempIfName               string `json:",omitempty"`
 	MacAddress               net.HardwareAddr
 	InfraVnetIP              net.IPNet
 	LocalIP                  string
@@ -40,6 +41,7 @@ type endpoint struct {
 	PODName                  string `json:",omitempty"`
 	PODNameSpace             string `json:",omitempty"`
 	InfraVnetAddressSpace    string `json:",omitempty"`
+	MTU                      int    `json:",omitempty"`
 }
 
 // EndpointInfo contains read-only information about an endpoint.
@@ -67,6 +69,7 @@ type EndpointInfo struct {
 	Data                     map[string]interface{}
 	InfraVnetAddressSpace    string
 	SkipHotAttachEp       bool
+	MTU                      int
 }
 
 // RouteInfo contains information about an IP route.
@@ -206,6 +209,7 @@ func (ep *endpoint) getInfo() *EndpointInfo {
 		NetNsPath:    ep.NetworkNameSpace,
 		PODName:      ep.PODName,
 		PODNameSpace: ep.PODNameSpace,
+		MTU:          ep.MTU,
 	}
 
 	for _, route := range ep.Routes {
@@ -72,6 +72,11 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		}
 	}
 
+	// Endpoints use the MTU of their network unless they configure their own.
+	if epInfo.MTU == 0 {
+		epInfo.MTU = nw.MTU
+	}
+
 	if _, ok := epInfo.Data[OptVethName]; ok {
 		key := epInfo.Data[OptVethName].(string)
 		log.Printf("Generate veth name based on the key provided %v", key)
@@ -85,7 +90,17 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		contIfName = fmt.Sprintf("%s%s-2", hostVEthInterfacePrefix, epInfo.Id[:7])
 	}
 
-	if vlanid != 0 {
+	if isSubInterfaceMode(nw.Mode) {
+		log.Printf("Sub-interface client for mode %v", nw.Mode)
+		if vlanid != 0 {
+			err = errNetworkModeInvalid
+			return nil, err
+		}
+
+		// IPVLAN and MACVLAN endpoints have no host-side interface.
+		hostIfName = ""
+		epClient = newSubInterfaceEndpointClient(nw.extIf, contIfName, nw.Mode)
+	} else if vlanid != 0 {
 		log.Printf("OVS client")
 		if _, ok := epInfo.Data[SnatBridgeIPKey]; ok {
 			nw.SnatBridgeIP = epInfo.Data[SnatBridgeIPKey].(string)
@@ -98,7 +113,7 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 			contIfName,
 			vlanid,
 			localIP)
-	} else if nw.Mode != opModeTransparent {
+	} else if nw.Mode != opModeTransparent && nw.Mode != opModeVxlan {
 		log.Printf("Bridge client")
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode)
 	} else {
@@ -123,6 +138,7 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 				EnableMultitenancy:       epInfo.EnableMultiTenancy,
 				AllowInboundFromHostToNC: epInfo.AllowInboundFromHostToNC,
 				AllowInboundFromNCToHost: epInfo.AllowInboundFromNCToHost,
+				NetworkNameSpace:         epInfo.NetNsPath,
 			}
 
 			if containerIf != nil {
@@ -209,6 +225,16 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		ContainerID:              epInfo.ContainerID,
 		PODName:                  epInfo.PODName,
 		PODNameSpace:             epInfo.PODNameSpace,
+		MTU:                      epInfo.MTU,
+	}
+
+	// IPVLAN and MACVLAN interfaces are deleted by their name in the container namespace,
+	// or by their temporary name if they were left behind in the host namespace.
+	if isSubInterfaceMode(nw.Mode) {
+		ep.TempIfName = contIfName
+		if epInfo.IfName != "" {
+			ep.IfName = epInfo.IfName
+		}
 	}
 
 	for _, route := range epInfo.Routes {
@@ -225,10 +251,13 @@ func (nw *network) deleteEndpointImpl(ep *endpoint) error {
 	// Delete the veth pair by deleting one of the peer interfaces.
 	// Deleting the host interface is more convenient since it does not require
 	// entering the container netns and hence works both for CNI and CNM.
-	if ep.VlanID != 0 {
+	if isSubInterfaceMode(nw.Mode) {
+		// Only the temporary name identifies the interface in the host namespace.
+		epClient = newSubInterfaceEndpointClient(nw.extIf, ep.TempIfName, nw.Mode)
+	} else if ep.VlanID != 0 {
 		epInfo := ep.getInfo()
 		epClient = NewOVSEndpointClient(nw, epInfo, ep.HostIfName, "", ep.VlanID, ep.LocalIP)
-	} else if nw.Mode != opModeTransparent {
+	} else if nw.Mode != opModeTransparent && nw.Mode != opModeVxlan {
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode)
 	} else {
 		epClient = NewTransparentEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode)
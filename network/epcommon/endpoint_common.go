@@ -41,13 +41,15 @@ func getFilterchainTarget() []string {
 	return actions
 }
 
-func CreateEndpoint(hostVethName string, containerVethName string) error {
-	log.Printf("[net] Creating veth pair %v %v.", hostVethName, containerVethName)
+// CreateEndpoint creates a veth pair. Both ends get the given MTU, or the kernel default if it is zero.
+func CreateEndpoint(hostVethName string, containerVethName string, mtu int) error {
+	log.Printf("[net] Creating veth pair %v %v with mtu %v.", hostVethName, containerVethName, mtu)
 
 	link := netlink.VEthLink{
 		LinkInfo: netlink.LinkInfo{
 			Type: netlink.LINK_TYPE_VETH,
 			Name: hostVethName,
+			MTU:  uint(mtu),
 		},
 		PeerName: containerVethName,
 	}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+// IPVLAN modes of the operational modes using IPVLAN endpoints.
+var ipvlanModes = map[string]netlink.IPVlanMode{
+	opModeIPVlan:    netlink.IPVLAN_MODE_L2,
+	opModeIPVlanL3:  netlink.IPVLAN_MODE_L3,
+	opModeIPVlanL3S: netlink.IPVLAN_MODE_L3S,
+}
+
+// IPVlanEndpointClient connects containers with IPVLAN sub-interfaces of the host interface.
+// Containers share the MAC address of the host interface.
+type IPVlanEndpointClient struct {
+	subInterfaceEndpointClient
+	mode netlink.IPVlanMode
+}
+
+func NewIPVlanEndpointClient(
+	extIf *externalInterface,
+	containerIfName string,
+	mode string,
+) *IPVlanEndpointClient {
+
+	client := &IPVlanEndpointClient{
+		subInterfaceEndpointClient: subInterfaceEndpointClient{
+			hostPrimaryIfName: extIf.Name,
+			containerIfName:   containerIfName,
+			linkType:          netlink.LINK_TYPE_IPVLAN,
+		},
+		mode: ipvlanModes[mode],
+	}
+
+	client.newLink = client.newIPVlanLink
+
+	return client
+}
+
+// Returns whether the given operational mode uses IPVLAN endpoints.
+func isIPVlanMode(mode string) bool {
+	_, ok := ipvlanModes[mode]
+	return ok
+}
+
+func (client *IPVlanEndpointClient) newIPVlanLink(name string, parentIndex int, mtu int) netlink.Link {
+	return &netlink.IPVlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_IPVLAN,
+			Name:        name,
+			MTU:         uint(mtu),
+			ParentIndex: parentIndex,
+		},
+		Mode: client.mode,
+	}
+}
@@ -0,0 +1,58 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+// MACVLAN modes of the operational modes using MACVLAN endpoints.
+var macvlanModes = map[string]netlink.MacvlanMode{
+	opModeMacvlan:         netlink.MACVLAN_MODE_BRIDGE,
+	opModeMacvlanPrivate:  netlink.MACVLAN_MODE_PRIVATE,
+	opModeMacvlanVepa:     netlink.MACVLAN_MODE_VEPA,
+	opModeMacvlanPassthru: netlink.MACVLAN_MODE_PASSTHRU,
+}
+
+// MacvlanEndpointClient connects containers with MACVLAN sub-interfaces of the host interface.
+// Each container gets its own MAC address on the segment of the host interface.
+type MacvlanEndpointClient struct {
+	subInterfaceEndpointClient
+	mode netlink.MacvlanMode
+}
+
+func NewMacvlanEndpointClient(
+	extIf *externalInterface,
+	containerIfName string,
+	mode string,
+) *MacvlanEndpointClient {
+
+	client := &MacvlanEndpointClient{
+		subInterfaceEndpointClient: subInterfaceEndpointClient{
+			hostPrimaryIfName: extIf.Name,
+			containerIfName:   containerIfName,
+			linkType:          netlink.LINK_TYPE_MACVLAN,
+		},
+		mode: macvlanModes[mode],
+	}
+
+	client.newLink = client.newMacvlanLink
+
+	return client
+}
+
+// Returns whether the given operational mode uses MACVLAN endpoints.
+func isMacvlanMode(mode string) bool {
+	_, ok := macvlanModes[mode]
+	return ok
+}
+
+func (client *MacvlanEndpointClient) newMacvlanLink(name string, parentIndex int, mtu int) netlink.Link {
+	return &netlink.MacvlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_MACVLAN,
+			Name:        name,
+			MTU:         uint(mtu),
+			ParentIndex: parentIndex,
+		},
+		Mode: client.mode,
+	}
+}
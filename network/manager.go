@@ -4,6 +4,7 @@
 package network
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -18,8 +19,14 @@ const (
 	storeKey    = "Network"
 	VlanIDKey   = "VlanID"
 	genericData = "com.docker.network.generic"
+
+	// Schema version of the persisted network manager state.
+	schemaVersion = 1
 )
 
+// Schema of the persisted network manager state.
+var stateSchema = store.NewSchema(schemaVersion)
+
 type NetworkClient interface {
 	CreateBridge() error
 	DeleteBridge() error
@@ -42,6 +49,7 @@ type EndpointClient interface {
 // NetworkManager manages the set of container networking resources.
 type networkManager struct {
 	Version            string
+	SchemaVersion      int
 	TimeStamp          time.Time
 	ExternalInterfaces map[string]*externalInterface
 	store              store.KeyValueStore
@@ -105,7 +113,7 @@ func (nm *networkManager) restore() error {
 	// Ignore the persisted state if it is older than the last reboot time.
 
 	// Read any persisted state.
-	err := nm.store.Read(storeKey, nm)
+	err := stateSchema.Read(nm.store, storeKey, nm)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
 			log.Printf("[net] network store key not found")
@@ -199,8 +207,9 @@ func (nm *networkManager) save() error {
 		return nil
 	}
 
-	// Update time stamp.
+	// Update time stamp and schema version.
 	nm.TimeStamp = time.Now()
+	nm.SchemaVersion = schemaVersion
 
 	err := nm.store.Write(storeKey, nm)
 	if err == nil {
@@ -211,6 +220,33 @@ func (nm *networkManager) save() error {
 	return err
 }
 
+// GetEndpointContainerIDs returns the IDs of the containers of all endpoints in persisted network manager state.
+// Returns store.ErrKeyNotFound if there is no persisted state, and an error if the container of an endpoint
+// is not recorded, such as for endpoints created by earlier versions.
+func GetEndpointContainerIDs(kvs store.KeyValueStore) ([]string, error) {
+	var nm networkManager
+	var containerIDs []string
+
+	err := stateSchema.Read(kvs, storeKey, &nm)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				if ep.ContainerID == "" {
+					return nil, fmt.Errorf("Container of endpoint %v is unknown", ep.Id)
+				}
+
+				containerIDs = append(containerIDs, ep.ContainerID)
+			}
+		}
+	}
+
+	return containerIDs, nil
+}
+
 //
 // NetworkManager API
 //
@@ -286,6 +322,7 @@ func (nm *networkManager) GetNetworkInfo(networkId string) (*NetworkInfo, error)
 		Subnets:          nw.Subnets,
 		Mode:             nw.Mode,
 		EnableSnatOnHost: nw.EnableSnatOnHost,
+		MTU:              nw.MTU,
 		DNS:              nw.DNS,
 		Options:          make(map[string]interface{}),
 	}
@@ -14,10 +14,21 @@ import (
 
 const (
 	// Operational modes.
-	opModeBridge      = "bridge"
-	opModeTunnel      = "tunnel"
-	opModeTransparent = "transparent"
-	opModeDefault     = opModeTunnel
+	opModeBridge          = "bridge"
+	opModeTunnel          = "tunnel"
+	opModeTransparent     = "transparent"
+	opModeIPVlan          = "ipvlan"
+	opModeIPVlanL3        = "ipvlan-l3"
+	opModeIPVlanL3S       = "ipvlan-l3s"
+	opModeMacvlan         = "macvlan"
+	opModeMacvlanPrivate  = "macvlan-private"
+	opModeMacvlanVepa     = "macvlan-vepa"
+	opModeMacvlanPassthru = "macvlan-passthru"
+	opModeVxlan           = "vxlan"
+	opModeDefault         = opModeTunnel
+
+	// Minimum MTU of IPv4 networks.
+	MinMTU = 68
 )
 
 // ExternalInterface is a host network interface that bridges containers to external networks.
@@ -40,6 +51,8 @@ type network struct {
 	HnsId            string `json:",omitempty"`
 	Mode             string
 	VlanId           int
+	Vxlan            *VxlanInfo `json:",omitempty"`
+	MTU              int        `json:",omitempty"`
 	Subnets          []SubnetInfo
 	Endpoints        map[string]*endpoint
 	extIf            *externalInterface
@@ -58,6 +71,7 @@ type NetworkInfo struct {
 	Policies         []policy.Policy
 	BridgeName       string
 	EnableSnatOnHost bool
+	MTU              int
 	Options          map[string]interface{}
 }
 
@@ -126,6 +140,20 @@ func (nm *networkManager) findExternalInterfaceBySubnet(subnet string) *external
 	return nil
 }
 
+// FindExternalInterfaceByIPAddress finds an external interface connected to the subnet of an IP address.
+func (nm *networkManager) findExternalInterfaceByIPAddress(ipAddress net.IP) *externalInterface {
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, s := range extIf.Subnets {
+			_, subnet, err := net.ParseCIDR(s)
+			if err == nil && subnet.Contains(ipAddress) {
+				return extIf
+			}
+		}
+	}
+
+	return nil
+}
+
 // FindExternalInterfaceByName finds an external interface by name.
 func (nm *networkManager) findExternalInterfaceByName(ifName string) *externalInterface {
 	extIf, exists := nm.ExternalInterfaces[ifName]
@@ -158,6 +186,10 @@ func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 	var extIf *externalInterface
 	if len(strings.TrimSpace(nwInfo.MasterIfName)) > 0 {
 		extIf = nm.findExternalInterfaceByName(nwInfo.MasterIfName)
+	} else if nwInfo.Mode == opModeVxlan {
+		// Overlay subnets are not assigned to any host interface,
+		// so use the interface of the local tunnel endpoint instead.
+		extIf = nm.findExternalInterfaceByIPAddress(getVxlanLocalIP(nwInfo.Options))
 	} else {
 		extIf = nm.findExternalInterfaceBySubnet(nwInfo.Subnets[0].Prefix.String())
 	}
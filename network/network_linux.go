@@ -47,9 +47,15 @@ type route netlink.Route
 func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInterface) (*network, error) {
 	// Connect the external interface.
 	var vlanid int
+	var vxlanInfo *VxlanInfo
 	opt, _ := nwInfo.Options[genericData].(map[string]interface{})
 	log.Printf("opt %+v options %+v", opt, nwInfo.Options)
 
+	// Validate the MTU before changing the host.
+	if err := validateMTU(nwInfo.MTU, extIf, nwInfo.Mode); err != nil {
+		return nil, err
+	}
+
 	switch nwInfo.Mode {
 	case opModeTunnel:
 		fallthrough
@@ -65,6 +71,27 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 
 	case opModeTransparent:
 		break
+	case opModeIPVlan, opModeIPVlanL3, opModeIPVlanL3S,
+		opModeMacvlan, opModeMacvlanPrivate, opModeMacvlanVepa, opModeMacvlanPassthru:
+		// IPVLAN and MACVLAN sub-interfaces are created on the host interface for each endpoint.
+		break
+	case opModeVxlan:
+		// Endpoints are routed like transparent endpoints, and reach other nodes through the overlay.
+		var err error
+		if vxlanInfo, err = parseVxlanInfo(opt); err != nil {
+			return nil, err
+		}
+
+		// The VXLAN interface is named after the VXLAN ID, so another network would replace it.
+		// Networks are recreated over their own interface after a reboot.
+		if owner := nm.findVxlanNetwork(vxlanInfo.VNI); owner != nil && owner.Id != nwInfo.Id {
+			log.Printf("[net] VXLAN ID %v is used by network %v.", vxlanInfo.VNI, owner.Id)
+			return nil, errVxlanIDInUse
+		}
+
+		if err = createVxlanInterface(extIf, vxlanInfo); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errNetworkModeInvalid
 	}
@@ -76,13 +103,67 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		Endpoints:        make(map[string]*endpoint),
 		extIf:            extIf,
 		VlanId:           vlanid,
+		Vxlan:            vxlanInfo,
+		MTU:              nwInfo.MTU,
 		DNS:              nwInfo.DNS,
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
 	}
 
+	if nw.MTU == 0 {
+		nw.MTU = getDefaultMTU(extIf, nw.Mode)
+	}
+
 	return nw, nil
 }
 
+// Returns the encapsulation overhead of a network mode, which is subtracted from the MTU of the external interface.
+// OVS VLAN tags in bridge and tunnel modes are part of the Ethernet header, so VLAN networks have no overhead.
+func getMTUOverhead(mode string) int {
+	switch mode {
+	case opModeVxlan:
+		return vxlanOverhead
+	default:
+		return 0
+	}
+}
+
+// Returns the MTU of networks that do not configure one,
+// which is the MTU of the external interface minus the encapsulation overhead.
+// Returns zero to leave the MTU to the kernel if the external interface is not found.
+func getDefaultMTU(extIf *externalInterface, mode string) int {
+	hostIf, err := net.InterfaceByName(extIf.Name)
+	if err != nil {
+		log.Printf("[net] Failed to get MTU of interface %v: %v.", extIf.Name, err)
+		return 0
+	}
+
+	return hostIf.MTU - getMTUOverhead(mode)
+}
+
+// Checks that a configured MTU is valid for IPv4 and fits on the external interface.
+// Zero is valid, and selects the default MTU.
+func validateMTU(mtu int, extIf *externalInterface, mode string) error {
+	if mtu == 0 {
+		return nil
+	}
+
+	if mtu < MinMTU {
+		return fmt.Errorf("Network MTU %d is invalid, the minimum is %d", mtu, MinMTU)
+	}
+
+	hostIf, err := net.InterfaceByName(extIf.Name)
+	if err != nil {
+		return err
+	}
+
+	maxMTU := hostIf.MTU - getMTUOverhead(mode)
+	if mtu > maxMTU {
+		return fmt.Errorf("Network MTU %d is invalid, the maximum on interface %v is %d", mtu, extIf.Name, maxMTU)
+	}
+
+	return nil
+}
+
 // DeleteNetworkImpl deletes an existing container network.
 func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 	var networkClient NetworkClient
@@ -93,8 +174,15 @@ func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 		networkClient = NewLinuxBridgeClient(nw.extIf.BridgeName, nw.extIf.Name, nw.Mode)
 	}
 
+	if nw.Vxlan != nil {
+		if err := deleteVxlanInterface(nw.Vxlan.VNI); err != nil {
+			return err
+		}
+	}
+
 	// Disconnect the interface if this was the last network using it.
-	if len(nw.extIf.Networks) == 1 {
+	// IPVLAN, MACVLAN and VXLAN networks do not connect the interface to a bridge.
+	if len(nw.extIf.Networks) == 1 && !isSubInterfaceMode(nw.Mode) && nw.Mode != opModeVxlan {
 		nm.disconnectExternalInterface(nw.extIf, networkClient)
 	}
 
@@ -372,6 +460,15 @@ func (nm *networkManager) connectExternalInterface(extIf *externalInterface, nwI
 		return err
 	}
 
+	// Set the configured bridge MTU. Otherwise the bridge follows the MTU of its ports.
+	if nwInfo.MTU > 0 {
+		log.Printf("[net] Setting link %v mtu %v.", bridgeName, nwInfo.MTU)
+		err = netlink.SetLinkMTU(bridgeName, nwInfo.MTU)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Bridge up.
 	log.Printf("[net] Setting link %v state up.", bridgeName)
 	err = netlink.SetLinkState(bridgeName, true)
@@ -449,6 +546,11 @@ func getNetworkInfoImpl(nwInfo *NetworkInfo, nw *network) {
 		vlanMap[VlanIDKey] = strconv.Itoa(nw.VlanId)
 		nwInfo.Options[genericData] = vlanMap
 	}
+
+	// The overlay configuration is needed to recreate VXLAN networks after reboot.
+	if nw.Vxlan != nil {
+		nwInfo.Options[genericData] = nw.Vxlan.getOptions()
+	}
 }
 
 func AddStaticRoute(ip string, interfaceName string) error {
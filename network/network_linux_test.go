@@ -0,0 +1,71 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+// Tests that networks without an MTU get the MTU of the external interface minus the encapsulation overhead.
+func TestGetDefaultMTU(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("Skipping, loopback interface not found, err:%v", err)
+	}
+
+	extIf := &externalInterface{Name: lo.Name}
+
+	tests := []struct {
+		mode string
+		mtu  int
+	}{
+		{opModeBridge, lo.MTU},
+		{opModeTransparent, lo.MTU},
+		{opModeIPVlan, lo.MTU},
+		{opModeVxlan, lo.MTU - vxlanOverhead},
+	}
+
+	for _, test := range tests {
+		if mtu := getDefaultMTU(extIf, test.mode); mtu != test.mtu {
+			t.Errorf("getDefaultMTU for mode %v returned %v, expected %v", test.mode, mtu, test.mtu)
+		}
+	}
+
+	if mtu := getDefaultMTU(&externalInterface{Name: "acnmissing0"}, opModeBridge); mtu != 0 {
+		t.Errorf("getDefaultMTU of a missing interface returned %v", mtu)
+	}
+}
+
+// Tests that configured MTUs must be valid for IPv4 and fit on the external interface.
+func TestValidateMTU(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("Skipping, loopback interface not found, err:%v", err)
+	}
+
+	extIf := &externalInterface{Name: lo.Name}
+
+	tests := []struct {
+		mtu   int
+		mode  string
+		valid bool
+	}{
+		{0, opModeBridge, true},
+		{MinMTU, opModeBridge, true},
+		{lo.MTU, opModeBridge, true},
+		{MinMTU - 1, opModeBridge, false},
+		{-1, opModeBridge, false},
+		{lo.MTU + 1, opModeBridge, false},
+		{lo.MTU - vxlanOverhead, opModeVxlan, true},
+		{lo.MTU, opModeVxlan, false},
+	}
+
+	for _, test := range tests {
+		err := validateMTU(test.mtu, extIf, test.mode)
+		if (err == nil) != test.valid {
+			t.Errorf("validateMTU of %v for mode %v returned err:%v", test.mtu, test.mode, err)
+		}
+	}
+}
@@ -60,7 +60,7 @@ func NewOVSEndpointClient(
 }
 
 func (client *OVSEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
-	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName); err != nil {
+	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
 		return err
 	}
 
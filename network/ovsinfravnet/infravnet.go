@@ -31,7 +31,7 @@ func NewInfraVnetClient(hostIfName string, contIfName string) OVSInfraVnetClient
 }
 
 func (client *OVSInfraVnetClient) CreateInfraVnetEndpoint(bridgeName string) error {
-	if err := epcommon.CreateEndpoint(client.hostInfraVethName, client.ContainerInfraVethName); err != nil {
+	if err := epcommon.CreateEndpoint(client.hostInfraVethName, client.ContainerInfraVethName, 0); err != nil {
 		log.Printf("Creating infraep failed with error %v", err)
 		return err
 	}
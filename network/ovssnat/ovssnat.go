@@ -75,7 +75,7 @@ func (client *OVSSnatClient) CreateSnatEndpoint(bridgeName string) error {
 	}
 
 	// Create veth pair to tie one end to container and other end to linux bridge
-	if err := epcommon.CreateEndpoint(client.hostSnatVethName, client.containerSnatVethName); err != nil {
+	if err := epcommon.CreateEndpoint(client.hostSnatVethName, client.containerSnatVethName, 0); err != nil {
 		log.Printf("Creating Snat Endpoint failed with error %v", err)
 		return err
 	}
@@ -0,0 +1,166 @@
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/epcommon"
+)
+
+// subInterfaceEndpointClient connects containers with sub-interfaces of the host interface,
+// such as IPVLAN and MACVLAN interfaces. Container traffic leaves through the host interface
+// without a bridge or host-side veth, so there are no host-side interfaces or rules to maintain.
+type subInterfaceEndpointClient struct {
+	hostPrimaryIfName string
+	containerIfName   string
+	linkType          string
+	newLink           func(name string, parentIndex int, mtu int) netlink.Link
+}
+
+// Returns whether the given operational mode uses sub-interface endpoints.
+func isSubInterfaceMode(mode string) bool {
+	return isIPVlanMode(mode) || isMacvlanMode(mode)
+}
+
+// Returns the endpoint client for a sub-interface operational mode.
+func newSubInterfaceEndpointClient(extIf *externalInterface, containerIfName string, mode string) EndpointClient {
+	if isMacvlanMode(mode) {
+		return NewMacvlanEndpointClient(extIf, containerIfName, mode)
+	}
+
+	return NewIPVlanEndpointClient(extIf, containerIfName, mode)
+}
+
+func (client *subInterfaceEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
+	// Delete the interface left behind by an earlier attempt.
+	if err := client.deleteHostSubInterface(); err != nil {
+		return err
+	}
+
+	hostIf, err := net.InterfaceByName(client.hostPrimaryIfName)
+	if err != nil {
+		return err
+	}
+
+	// The interface is created in the host namespace under a temporary name,
+	// and moved into the container namespace before it is configured.
+	log.Printf("[net] Creating %v interface %v on %v with mtu %v.",
+		client.linkType, client.containerIfName, client.hostPrimaryIfName, epInfo.MTU)
+
+	if err = netlink.AddLink(client.newLink(client.containerIfName, hostIf.Index, epInfo.MTU)); err != nil {
+		log.Printf("[net] Failed to create %v interface, err:%v.", client.linkType, err)
+		return err
+	}
+
+	return nil
+}
+
+func (client *subInterfaceEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
+	return nil
+}
+
+func (client *subInterfaceEndpointClient) DeleteEndpointRules(ep *endpoint) {
+}
+
+func (client *subInterfaceEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
+	// Move the container interface to container's network namespace.
+	log.Printf("[net] Setting link %v netns %v.", client.containerIfName, epInfo.NetNsPath)
+	if err := netlink.SetLinkNetNs(client.containerIfName, nsID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (client *subInterfaceEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo) error {
+	if err := epcommon.SetupContainerInterface(client.containerIfName, epInfo.IfName); err != nil {
+		return err
+	}
+
+	client.containerIfName = epInfo.IfName
+
+	return nil
+}
+
+func (client *subInterfaceEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
+	if err := epcommon.AssignIPToInterface(client.containerIfName, epInfo.IPAddresses); err != nil {
+		return err
+	}
+
+	return addRoutes(client.containerIfName, epInfo.Routes)
+}
+
+func (client *subInterfaceEndpointClient) DeleteEndpoints(ep *endpoint) error {
+	// The interface is still in the host namespace under its temporary name
+	// if the endpoint failed before it was moved.
+	if err := client.deleteHostSubInterface(); err != nil {
+		return err
+	}
+
+	if ep.NetworkNameSpace == "" {
+		return nil
+	}
+
+	// The interface is deleted with the container namespace, if that is already gone.
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		log.Printf("[net] Not deleting %v interface, failed to open netns %v: %v.", client.linkType, ep.NetworkNameSpace, err)
+		return nil
+	}
+	defer ns.Close()
+
+	log.Printf("[net] Entering netns %v.", ep.NetworkNameSpace)
+	if err = ns.Enter(); err != nil {
+		return err
+	}
+
+	defer func() {
+		log.Printf("[net] Exiting netns %v.", ep.NetworkNameSpace)
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	log.Printf("[net] Deleting %v interface %v in netns.", client.linkType, ep.IfName)
+	if err = netlink.DeleteLink(ep.IfName); err != nil {
+		log.Printf("[net] Failed to delete %v interface %v in netns: %v.", client.linkType, ep.IfName, err)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes the interface of the endpoint left behind in the host namespace under its temporary name.
+// The container interface name, such as eth0, is never used in the host namespace, where it
+// names a host interface. The interface is only deleted if it is a sub-interface of the host interface.
+func (client *subInterfaceEndpointClient) deleteHostSubInterface() error {
+	if client.containerIfName == "" {
+		return nil
+	}
+
+	link, err := netlink.GetLink(client.containerIfName)
+	if err != nil {
+		// The interface was moved to the container namespace, or never created.
+		return nil
+	}
+
+	hostIf, err := net.InterfaceByName(client.hostPrimaryIfName)
+	if err != nil {
+		return err
+	}
+
+	if link.Type != client.linkType || link.ParentIndex != hostIf.Index {
+		log.Printf("[net] Not deleting interface %v, it is not a %v interface on %v.",
+			client.containerIfName, client.linkType, client.hostPrimaryIfName)
+		return nil
+	}
+
+	log.Printf("[net] Deleting %v interface %v.", client.linkType, client.containerIfName)
+	if err = netlink.DeleteLink(client.containerIfName); err != nil {
+		log.Printf("[net] Failed to delete %v interface %v: %v.", client.linkType, client.containerIfName, err)
+		return err
+	}
+
+	return nil
+}
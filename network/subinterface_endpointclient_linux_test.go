@@ -0,0 +1,125 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	testMasterIfName = "acnmaster0"
+	testHostIfName   = "acnhost0"
+	testNetNsName    = "acntest"
+	testNetNsPath    = "/var/run/netns/" + testNetNsName
+)
+
+func addTestDummyInterface(name string) error {
+	return netlink.AddLink(&netlink.DummyLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_DUMMY,
+			Name: name,
+		},
+	})
+}
+
+// Returns whether an interface exists in the test network namespace.
+func interfaceExistsInTestNetNs(t *testing.T, name string) bool {
+	ns, err := OpenNamespace(testNetNsPath)
+	if err != nil {
+		t.Fatalf("OpenNamespace failed, err:%v", err)
+	}
+	defer ns.Close()
+
+	if err = ns.Enter(); err != nil {
+		t.Fatalf("Enter netns failed, err:%v", err)
+	}
+	defer ns.Exit()
+
+	_, err = net.InterfaceByName(name)
+	return err == nil
+}
+
+// Creates and deletes an endpoint of a sub-interface mode on a dummy master interface, named like CNI names it.
+// The container interface is named after a host interface, which must survive the endpoint.
+// An interface left behind in the host namespace under the temporary name is deleted with the endpoint.
+func testSubInterfaceEndpoint(t *testing.T, mode string) {
+	if err := addTestDummyInterface(testMasterIfName); err != nil {
+		t.Skipf("Skipping, failed to create dummy interface, err:%v", err)
+	}
+	defer netlink.DeleteLink(testMasterIfName)
+
+	if err := addTestDummyInterface(testHostIfName); err != nil {
+		t.Fatalf("Failed to create host interface, err:%v", err)
+	}
+	defer netlink.DeleteLink(testHostIfName)
+
+	if _, err := platform.ExecuteCommand("ip netns add " + testNetNsName); err != nil {
+		t.Fatalf("Failed to create netns, err:%v", err)
+	}
+	defer platform.ExecuteCommand("ip netns delete " + testNetNsName)
+
+	nw := &network{
+		Id:        "test",
+		Mode:      mode,
+		Endpoints: make(map[string]*endpoint),
+		extIf:     &externalInterface{Name: testMasterIfName},
+	}
+
+	epInfo := &EndpointInfo{
+		Id:        "12345678-eth0",
+		IfName:    testHostIfName,
+		NetNsPath: testNetNsPath,
+		IPAddresses: []net.IPNet{
+			{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)},
+		},
+		Data: map[string]interface{}{OptVethName: "default.testpod"},
+	}
+
+	ep, err := nw.newEndpointImpl(epInfo)
+	if err != nil {
+		t.Fatalf("newEndpointImpl failed, err:%v", err)
+	}
+
+	tempIfName := hostVEthInterfacePrefix + generateVethName("default.testpod") + "2"
+	if ep.TempIfName != tempIfName {
+		t.Errorf("Endpoint has temporary interface name %v, expected %v", ep.TempIfName, tempIfName)
+	}
+
+	// Leave an interface behind under the temporary name, like a failed attempt.
+	if err = newSubInterfaceEndpointClient(nw.extIf, tempIfName, mode).AddEndpoints(epInfo); err != nil {
+		t.Fatalf("Failed to create interface %v, err:%v", tempIfName, err)
+	}
+
+	if !interfaceExistsInTestNetNs(t, testHostIfName) {
+		t.Errorf("Container interface %v not found in netns", testHostIfName)
+	}
+
+	if err = nw.deleteEndpointImpl(ep); err != nil {
+		t.Errorf("deleteEndpointImpl failed, err:%v", err)
+	}
+
+	if interfaceExistsInTestNetNs(t, testHostIfName) {
+		t.Errorf("Container interface %v not deleted from netns", testHostIfName)
+	}
+
+	if _, err = net.InterfaceByName(tempIfName); err == nil {
+		t.Errorf("Interface %v left behind in the host namespace not deleted", tempIfName)
+	}
+
+	if _, err = net.InterfaceByName(testHostIfName); err != nil {
+		t.Errorf("Host interface %v deleted with the endpoint, err:%v", testHostIfName, err)
+	}
+}
+
+func TestIPVlanEndpoint(t *testing.T) {
+	testSubInterfaceEndpoint(t, opModeIPVlan)
+}
+
+func TestMacvlanEndpoint(t *testing.T) {
+	testSubInterfaceEndpoint(t, opModeMacvlan)
+}
@@ -61,7 +61,7 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 		}
 	}
 
-	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName); err != nil {
+	if err := epcommon.CreateEndpoint(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
 		return err
 	}
 
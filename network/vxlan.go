@@ -0,0 +1,126 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Generic network options of VXLAN overlay networks.
+	VxlanIDKey      = "VxlanID"
+	VxlanPortKey    = "VxlanPort"
+	VxlanLocalIPKey = "VxlanLocalIP"
+	VxlanPeersKey   = "VxlanPeers"
+
+	// Defaults for VXLAN overlay networks.
+	defaultVxlanID   = 4096
+	defaultVxlanPort = 4789
+)
+
+// VxlanInfo contains the VXLAN overlay configuration of a network.
+type VxlanInfo struct {
+	VNI     int
+	Port    int
+	LocalIP net.IP
+	Peers   []VxlanPeer
+}
+
+// VxlanPeer is a node of a VXLAN overlay network and the subnet of its containers.
+type VxlanPeer struct {
+	NodeIP net.IP
+	Subnet net.IPNet
+}
+
+// Returns the address of the local tunnel endpoint in the generic options of a network.
+func getVxlanLocalIP(options map[string]interface{}) net.IP {
+	opt, _ := options[genericData].(map[string]interface{})
+	localIP, _ := opt[VxlanLocalIPKey].(string)
+	return net.ParseIP(localIP)
+}
+
+// Returns the network using a VXLAN ID, if any.
+func (nm *networkManager) findVxlanNetwork(vni int) *network {
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			if nw.Vxlan != nil && nw.Vxlan.VNI == vni {
+				return nw
+			}
+		}
+	}
+
+	return nil
+}
+
+// Parses the VXLAN overlay configuration in the generic options of a network.
+// Peers are listed as comma-separated nodeIP=subnet pairs, and may include the local node.
+// Only IPv4 tunnel endpoints are supported.
+func parseVxlanInfo(opt map[string]interface{}) (*VxlanInfo, error) {
+	info := &VxlanInfo{
+		VNI:  defaultVxlanID,
+		Port: defaultVxlanPort,
+	}
+
+	var err error
+
+	if value, ok := opt[VxlanIDKey].(string); ok {
+		info.VNI, err = strconv.Atoi(value)
+		if err != nil || info.VNI <= 0 || info.VNI >= 1<<24 {
+			return nil, errVxlanConfigInvalid
+		}
+	}
+
+	if value, ok := opt[VxlanPortKey].(string); ok {
+		info.Port, err = strconv.Atoi(value)
+		if err != nil || info.Port <= 0 || info.Port >= 1<<16 {
+			return nil, errVxlanConfigInvalid
+		}
+	}
+
+	localIP, _ := opt[VxlanLocalIPKey].(string)
+	info.LocalIP = net.ParseIP(localIP).To4()
+	if info.LocalIP == nil {
+		return nil, errVxlanConfigInvalid
+	}
+
+	peers, _ := opt[VxlanPeersKey].(string)
+	for _, value := range strings.Split(peers, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		pair := strings.Split(strings.TrimSpace(value), "=")
+		if len(pair) != 2 {
+			return nil, errVxlanConfigInvalid
+		}
+
+		nodeIP := net.ParseIP(pair[0]).To4()
+		_, subnet, err := net.ParseCIDR(pair[1])
+		if nodeIP == nil || err != nil {
+			return nil, errVxlanConfigInvalid
+		}
+
+		info.Peers = append(info.Peers, VxlanPeer{NodeIP: nodeIP, Subnet: *subnet})
+	}
+
+	return info, nil
+}
+
+// Returns the generic network options of a VXLAN overlay configuration.
+func (info *VxlanInfo) getOptions() map[string]interface{} {
+	var peers []string
+	for _, peer := range info.Peers {
+		peers = append(peers, fmt.Sprintf("%s=%s", peer.NodeIP.String(), peer.Subnet.String()))
+	}
+
+	return map[string]interface{}{
+		VxlanIDKey:      strconv.Itoa(info.VNI),
+		VxlanPortKey:    strconv.Itoa(info.Port),
+		VxlanLocalIPKey: info.LocalIP.String(),
+		VxlanPeersKey:   strings.Join(peers, ","),
+	}
+}
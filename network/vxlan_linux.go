@@ -0,0 +1,158 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Prefix for VXLAN interface names, followed by the VNI.
+	vxlanInterfacePrefix = "azvxlan"
+
+	// Size of the outer IPv4, UDP and VXLAN headers and the inner Ethernet header.
+	vxlanOverhead = 50
+)
+
+// Returns the name of the VXLAN interface of an overlay network.
+func getVxlanInterfaceName(vni int) string {
+	return fmt.Sprintf("%s%d", vxlanInterfacePrefix, vni)
+}
+
+// Returns the MAC address of the tunnel endpoint of a node.
+// Addresses are derived from node addresses, so that nodes know the tunnel endpoints
+// of their peers without resolving them over the overlay.
+func getVtepMacAddress(nodeIP net.IP) net.HardwareAddr {
+	ip := nodeIP.To4()
+	return net.HardwareAddr{0x0a, 0x58, ip[0], ip[1], ip[2], ip[3]}
+}
+
+// CreateVxlanInterface creates the VXLAN interface of an overlay network on an external interface,
+// and routes the container subnets of the peer nodes through their tunnel endpoints.
+// The caller makes sure that no other network uses the VXLAN ID.
+func createVxlanInterface(extIf *externalInterface, info *VxlanInfo) error {
+	ifName := getVxlanInterfaceName(info.VNI)
+
+	hostIf, err := net.InterfaceByName(extIf.Name)
+	if err != nil {
+		return err
+	}
+
+	// No other network uses the VXLAN ID, so an existing interface was left behind by a network
+	// that was not deleted, possibly with a different configuration. Recreate it.
+	if _, err = net.InterfaceByName(ifName); err == nil {
+		log.Printf("[net] Deleting old VXLAN interface %v.", ifName)
+		if err = netlink.DeleteLink(ifName); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[net] Creating VXLAN interface %v on %v with local IP %v port %v.",
+		ifName, extIf.Name, info.LocalIP, info.Port)
+
+	link := netlink.VxlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_VXLAN,
+			Name:        ifName,
+			ParentIndex: hostIf.Index,
+		},
+		VNI:     uint32(info.VNI),
+		LocalIP: info.LocalIP,
+		Port:    uint16(info.Port),
+	}
+
+	if err = netlink.AddLink(&link); err != nil {
+		log.Printf("[net] Failed to create VXLAN interface, err:%v.", err)
+		return err
+	}
+
+	// On failure, delete the interface.
+	defer func() {
+		if err != nil {
+			netlink.DeleteLink(ifName)
+		}
+	}()
+
+	if err = netlink.SetLinkAddress(ifName, getVtepMacAddress(info.LocalIP)); err != nil {
+		return err
+	}
+
+	if err = netlink.SetLinkState(ifName, true); err != nil {
+		return err
+	}
+
+	vxlanIf, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range info.Peers {
+		if peer.NodeIP.Equal(info.LocalIP) {
+			continue
+		}
+
+		if err = addVxlanPeer(vxlanIf, peer); err != nil {
+			log.Printf("[net] Failed to add VXLAN peer %+v, err:%v.", peer, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Routes the container subnet of a peer node through its tunnel endpoint.
+// The network address of the subnet, which is never assigned to a container,
+// stands for the tunnel endpoint as the on-link gateway in the overlay.
+func addVxlanPeer(vxlanIf *net.Interface, peer VxlanPeer) error {
+	mac := getVtepMacAddress(peer.NodeIP)
+	gateway := peer.Subnet.IP
+
+	log.Printf("[net] Adding VXLAN peer %v for subnet %v.", peer.NodeIP, peer.Subnet.String())
+
+	// bridge fdb add <vtepmac> dev <vxlan> dst <nodeip> self permanent
+	if err := netlink.AddOrRemoveFdbEntry(netlink.ADD, vxlanIf.Name, mac, peer.NodeIP); err != nil {
+		return err
+	}
+
+	// ip neigh add <gateway> lladdr <vtepmac> dev <vxlan> nud permanent
+	if err := netlink.AddOrRemoveStaticArp(netlink.ADD, vxlanIf.Name, gateway, mac); err != nil {
+		return err
+	}
+
+	// ip route add <subnet> via <gateway> dev <vxlan> onlink
+	route := &netlink.Route{
+		Family:    unix.AF_INET,
+		Dst:       &peer.Subnet,
+		Gw:        gateway,
+		LinkIndex: vxlanIf.Index,
+		Flags:     unix.RTNH_F_ONLINK,
+	}
+
+	return netlink.AddIpRoute(route)
+}
+
+// DeleteVxlanInterface deletes the VXLAN interface of an overlay network,
+// along with its forwarding entries and routes.
+// Interfaces that no longer exist, such as after a reboot, are ignored.
+func deleteVxlanInterface(vni int) error {
+	ifName := getVxlanInterfaceName(vni)
+
+	if _, err := net.InterfaceByName(ifName); err != nil {
+		log.Printf("[net] VXLAN interface %v not found, err:%v.", ifName, err)
+		return nil
+	}
+
+	log.Printf("[net] Deleting VXLAN interface %v.", ifName)
+	if err := netlink.DeleteLink(ifName); err != nil {
+		log.Printf("[net] Failed to delete VXLAN interface %v, err:%v.", ifName, err)
+		return err
+	}
+
+	return nil
+}
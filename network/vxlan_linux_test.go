@@ -0,0 +1,103 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Tests that the VXLAN interface of an overlay network routes peer subnets through their tunnel endpoints.
+func TestCreateDeleteVxlanInterface(t *testing.T) {
+	if err := addTestDummyInterface(testMasterIfName); err != nil {
+		t.Skipf("Skipping, failed to create dummy interface, err:%v", err)
+	}
+	defer netlink.DeleteLink(testMasterIfName)
+
+	if err := netlink.SetLinkState(testMasterIfName, true); err != nil {
+		t.Fatalf("SetLinkState failed, err:%v", err)
+	}
+
+	_, localSubnet, _ := net.ParseCIDR("10.240.0.0/24")
+	_, peerSubnet, _ := net.ParseCIDR("10.240.1.0/24")
+
+	info := &VxlanInfo{
+		VNI:     4097,
+		Port:    defaultVxlanPort,
+		LocalIP: net.ParseIP("10.0.0.4"),
+		Peers: []VxlanPeer{
+			{NodeIP: net.ParseIP("10.0.0.4"), Subnet: *localSubnet},
+			{NodeIP: net.ParseIP("10.0.0.5"), Subnet: *peerSubnet},
+		},
+	}
+
+	err := createVxlanInterface(&externalInterface{Name: testMasterIfName}, info)
+	if err != nil {
+		t.Fatalf("createVxlanInterface failed, err:%v", err)
+	}
+
+	vxlanIf, err := net.InterfaceByName(getVxlanInterfaceName(info.VNI))
+	if err != nil {
+		t.Fatalf("VXLAN interface not found, err:%v", err)
+	}
+
+	if vxlanIf.HardwareAddr.String() != "0a:58:0a:00:00:04" {
+		t.Errorf("VXLAN interface has MAC address %v", vxlanIf.HardwareAddr)
+	}
+
+	filter := &netlink.Route{Family: unix.AF_INET, LinkIndex: vxlanIf.Index, Dst: peerSubnet}
+	routes, err := netlink.GetIpRoute(filter)
+	if err != nil || len(routes) != 1 || !routes[0].Gw.Equal(peerSubnet.IP) {
+		t.Errorf("Peer subnet route not found, routes:%+v err:%v", routes, err)
+	}
+
+	// The local subnet is not routed through the overlay.
+	filter.Dst = localSubnet
+	routes, err = netlink.GetIpRoute(filter)
+	if err != nil || len(routes) != 0 {
+		t.Errorf("Local subnet routed through the overlay, routes:%+v err:%v", routes, err)
+	}
+
+	if err = deleteVxlanInterface(info.VNI); err != nil {
+		t.Errorf("deleteVxlanInterface failed, err:%v", err)
+	}
+
+	if _, err = net.InterfaceByName(vxlanIf.Name); err == nil {
+		t.Errorf("VXLAN interface not deleted")
+	}
+}
+
+// Tests that overlay networks are not created with the VXLAN ID of another network.
+func TestVxlanIDInUse(t *testing.T) {
+	extIf := &externalInterface{
+		Name:     testMasterIfName,
+		Networks: make(map[string]*network),
+	}
+
+	extIf.Networks["overlay1"] = &network{
+		Id:    "overlay1",
+		Mode:  opModeVxlan,
+		Vxlan: &VxlanInfo{VNI: defaultVxlanID},
+		extIf: extIf,
+	}
+
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{extIf.Name: extIf},
+	}
+
+	nwInfo := &NetworkInfo{
+		Id:   "overlay2",
+		Mode: opModeVxlan,
+		Options: map[string]interface{}{
+			genericData: map[string]interface{}{VxlanLocalIPKey: "10.0.0.4"},
+		},
+	}
+
+	if _, err := nm.newNetworkImpl(nwInfo, extIf); err != errVxlanIDInUse {
+		t.Errorf("newNetworkImpl with VXLAN ID of another network returned err:%v", err)
+	}
+}
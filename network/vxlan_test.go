@@ -0,0 +1,49 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests that VXLAN overlay configurations are parsed from generic network options.
+func TestParseVxlanInfo(t *testing.T) {
+	opt := map[string]interface{}{
+		VxlanLocalIPKey: "10.0.0.4",
+		VxlanPeersKey:   "10.0.0.4=10.240.0.0/24, 10.0.0.5=10.240.1.0/24",
+	}
+
+	info, err := parseVxlanInfo(opt)
+	if err != nil {
+		t.Fatalf("parseVxlanInfo failed, err:%v", err)
+	}
+
+	if info.VNI != defaultVxlanID || info.Port != defaultVxlanPort || info.LocalIP.String() != "10.0.0.4" {
+		t.Errorf("parseVxlanInfo returned %+v", info)
+	}
+
+	if len(info.Peers) != 2 || info.Peers[1].NodeIP.String() != "10.0.0.5" || info.Peers[1].Subnet.String() != "10.240.1.0/24" {
+		t.Errorf("parseVxlanInfo returned peers %+v", info.Peers)
+	}
+
+	// Options of parsed configurations parse to the same configuration.
+	if parsed, err := parseVxlanInfo(info.getOptions()); err != nil || !reflect.DeepEqual(parsed, info) {
+		t.Errorf("parseVxlanInfo of options returned %+v err:%v, expected %+v", parsed, err, info)
+	}
+
+	invalid := []map[string]interface{}{
+		{},
+		{VxlanLocalIPKey: "10.0.0.4", VxlanIDKey: "16777216"},
+		{VxlanLocalIPKey: "10.0.0.4", VxlanPortKey: "port"},
+		{VxlanLocalIPKey: "10.0.0.4", VxlanPeersKey: "10.0.0.5"},
+		{VxlanLocalIPKey: "fd00::4"},
+	}
+
+	for _, opt := range invalid {
+		if _, err := parseVxlanInfo(opt); err != errVxlanConfigInvalid {
+			t.Errorf("parseVxlanInfo of %+v returned err:%v", opt, err)
+		}
+	}
+}
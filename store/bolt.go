@@ -0,0 +1,375 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// Default file name for backing bolt database.
+	defaultBoltFileName = "azure-container-networking.db"
+
+	// Name of the bucket holding the key value pairs.
+	boltBucketName = "store"
+
+	// Permissions of the database file.
+	boltFileMode = 0664
+
+	// Maximum time to wait for another process to close the database file.
+	boltOpenTimeout = 10 * time.Second
+
+	// Extension of the file holding the digests of all values, rewritten after each commit.
+	// Watchers read it instead of the database, which the writer may keep open.
+	boltDigestExtension = ".digests"
+)
+
+// boltStore is an implementation of KeyValueStore using an embedded bolt database file.
+// Each key is stored separately, so writes do not rewrite the values of other keys.
+// Bolt allows only one process at a time to open the database, so the file is kept open
+// while the store is locked and opened for the duration of each operation otherwise.
+type boltStore struct {
+	fileName string
+	db       *bolt.DB
+	locked   bool
+	sync.Mutex
+}
+
+// NewBoltStore creates a new boltStore object, accessed as a KeyValueStore.
+func NewBoltStore(fileName string) (KeyValueStore, error) {
+	if fileName == "" {
+		fileName = defaultBoltFileName
+	}
+
+	kvs := &boltStore{
+		fileName: fileName,
+	}
+
+	return kvs, nil
+}
+
+// Imports the state of a JSON file store into a new bolt database, unless the database already exists.
+// The import is serialized with other processes by the lock file of the database, and written to
+// a temporary file first, so that a failed import is retried instead of leaving an empty database.
+func importJsonFileStoreOnce(jsonFileName string, dbFileName string) error {
+	if _, err := os.Stat(dbFileName); !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, err := os.Stat(jsonFileName); os.IsNotExist(err) {
+		return nil
+	}
+
+	lockName := dbFileName + lockExtension
+	if err := acquireLockFile(lockName, true); err != nil {
+		return err
+	}
+	defer os.Remove(lockName)
+
+	// Another process may have imported the store while this one waited for the lock.
+	if _, err := os.Stat(dbFileName); !os.IsNotExist(err) {
+		return err
+	}
+
+	tempName := dbFileName + tempExtension
+	os.Remove(tempName)
+
+	kvs, _ := NewBoltStore(tempName)
+	if err := ImportJsonFileStore(jsonFileName, kvs); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	if err := os.Rename(tempName, dbFileName); err != nil {
+		os.Remove(tempName)
+		os.Remove(tempName + boltDigestExtension)
+		return err
+	}
+
+	os.Rename(tempName+boltDigestExtension, dbFileName+boltDigestExtension)
+
+	log.Printf("[store] Imported %v into %v.", jsonFileName, dbFileName)
+
+	return nil
+}
+
+// Opens a bolt database file, creating it if it does not exist.
+// Read-only databases are opened without modifying the file, which would notify watchers.
+func openBoltDB(fileName string, readOnly bool) (*bolt.DB, error) {
+	options := &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: readOnly}
+
+	db, err := bolt.Open(fileName, boltFileMode, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if readOnly {
+		return db, nil
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltBucketName))
+		return err
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Lock-free helper for internal callers.
+// Runs the given function on the database, opening it for the duration of the call if needed.
+func (kvs *boltStore) withDB(readOnly bool, fn func(db *bolt.DB) error) error {
+	db := kvs.db
+
+	if db == nil {
+		var err error
+		db, err = openBoltDB(kvs.fileName, readOnly)
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+	}
+
+	return fn(db)
+}
+
+// Read restores the value for the given key from persistent store.
+func (kvs *boltStore) Read(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	// Do not create the database just to find out it is empty.
+	if kvs.db == nil {
+		if _, err := os.Stat(kvs.fileName); os.IsNotExist(err) {
+			return ErrKeyNotFound
+		}
+	}
+
+	var raw []byte
+
+	err := kvs.withDB(true, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(boltBucketName))
+			if bucket == nil {
+				return ErrKeyNotFound
+			}
+
+			buf := bucket.Get([]byte(key))
+			if buf == nil {
+				return ErrKeyNotFound
+			}
+
+			// Values are only valid for the lifetime of the bolt transaction.
+			raw = append([]byte(nil), buf...)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, value)
+}
+
+// Write saves the given key value pair to persistent store.
+func (kvs *boltStore) Write(key string, value interface{}) error {
+	var raw json.RawMessage
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return kvs.commit(map[string]*json.RawMessage{key: &raw})
+}
+
+// BeginTransaction starts a new transaction on the store.
+func (kvs *boltStore) BeginTransaction() Transaction {
+	return newTransaction(kvs.Read, kvs.commit)
+}
+
+// Saves the given key value pairs to persistent store in a single bolt transaction.
+func (kvs *boltStore) commit(writes map[string]*json.RawMessage) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.withDB(false, func(db *bolt.DB) error {
+		digests := make(map[string][]byte)
+
+		err := db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(boltBucketName))
+
+			for key, raw := range writes {
+				if err := bucket.Put([]byte(key), *raw); err != nil {
+					return err
+				}
+			}
+
+			return bucket.ForEach(func(key []byte, value []byte) error {
+				digest := sha256.Sum256(value)
+				digests[string(key)] = digest[:]
+				return nil
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// Notify watchers while the database is still open, so that commits of other processes
+		// cannot overwrite the digests of this one. The transaction is already committed.
+		if err := kvs.writeDigests(digests); err != nil {
+			log.Printf("[store] Failed to write digests of %v, err:%v.", kvs.fileName, err)
+		}
+
+		return nil
+	})
+}
+
+// Lock-free helper for internal callers.
+// Replaces the digest file of the database, so that watchers are notified with a single event.
+func (kvs *boltStore) writeDigests(digests map[string][]byte) error {
+	buf, err := json.Marshal(digests)
+	if err != nil {
+		return err
+	}
+
+	fileName := kvs.fileName + boltDigestExtension
+	tempName := fileName + tempExtension
+
+	if err = ioutil.WriteFile(tempName, buf, boltFileMode); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	if err = os.Rename(tempName, fileName); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	return nil
+}
+
+// Flush commits in-memory state to persistent store.
+// Writes are committed to the database immediately, so there is nothing to flush.
+func (kvs *boltStore) Flush() error {
+	return nil
+}
+
+// Watch calls onChange with the keys that changed each time the persistent store is written,
+// including by other processes. Returns a function that stops watching.
+// Changes are detected from the digest file, which is rewritten after each commit, so watchers are
+// notified even while the writer keeps the database open.
+func (kvs *boltStore) Watch(onChange func(keys []string)) (func(), error) {
+	return watchStore(kvs.fileName+boltDigestExtension, kvs.readDigests, onChange)
+}
+
+// Reads the digests of all values from the digest file for watchers.
+// Other processes cannot open the database while the writer keeps it open.
+func (kvs *boltStore) readDigests() (map[string][]byte, error) {
+	digests := make(map[string][]byte)
+
+	buf, err := ioutil.ReadFile(kvs.fileName + boltDigestExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return digests, nil
+		}
+		return nil, err
+	}
+
+	if err = json.Unmarshal(buf, &digests); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
+
+// Lock locks the store for exclusive access.
+func (kvs *boltStore) Lock(block bool) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if kvs.locked {
+		return ErrStoreLocked
+	}
+
+	lockName := kvs.fileName + lockExtension
+
+	if err := acquireLockFile(lockName, block); err != nil {
+		return err
+	}
+
+	// Keep the database open while the store is locked.
+	db, err := openBoltDB(kvs.fileName, false)
+	if err != nil {
+		os.Remove(lockName)
+		return err
+	}
+
+	kvs.db = db
+	kvs.locked = true
+
+	return nil
+}
+
+// Unlock unlocks the store.
+func (kvs *boltStore) Unlock(forceUnlock bool) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if !forceUnlock && !kvs.locked {
+		return ErrStoreNotLocked
+	}
+
+	if kvs.db != nil {
+		if err := kvs.db.Close(); err != nil {
+			log.Printf("[store] Failed to close %v, err:%v.", kvs.fileName, err)
+		}
+		kvs.db = nil
+	}
+
+	err := os.Remove(kvs.fileName + lockExtension)
+	if err != nil {
+		return err
+	}
+
+	kvs.locked = false
+
+	return nil
+}
+
+// GetModificationTime returns the modification time of the persistent store.
+func (kvs *boltStore) GetModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	info, err := os.Stat(kvs.fileName)
+	if err != nil {
+		log.Printf("os.stat() for file %v failed: %v", kvs.fileName, err)
+		return time.Time{}.UTC(), err
+	}
+
+	return info.ModTime().UTC(), nil
+}
+
+// GetLockFileModificationTime returns the modification time of the lock file of the persistent store.
+func (kvs *boltStore) GetLockFileModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return getLockFileModificationTime(kvs.fileName + lockExtension)
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+const (
+	// Path used for test stores, without extension.
+	testStorePath = "test"
+)
+
+// Tests that key value pairs are written and read correctly from a bolt store.
+func TestBoltStoreWritesAndReads(t *testing.T) {
+	var value testType1
+
+	fileName := testStorePath + boltExtension
+	defer os.Remove(fileName)
+	defer os.Remove(fileName + boltDigestExtension)
+
+	kvs, err := NewBoltStore(fileName)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v\n", err)
+	}
+
+	// Missing keys are reported without creating the database.
+	err = kvs.Read(testKey1, &value)
+	if err != ErrKeyNotFound {
+		t.Errorf("Read of empty store returned err:%v", err)
+	}
+
+	if _, err = os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("Read created the database, err:%v", err)
+	}
+
+	// Reads and writes work with and without the store lock.
+	for _, lock := range []bool{false, true} {
+		if lock {
+			if err = kvs.Lock(true); err != nil {
+				t.Fatalf("Failed to lock store %v", err)
+			}
+		}
+
+		err = Update(kvs, func(tx Transaction) error {
+			tx.Write(testKey1, &testType1{"test", 1})
+			return tx.Write(testKey2, &testType1{"test", 2})
+		})
+
+		if err != nil {
+			t.Fatalf("Failed to update store %v", err)
+		}
+
+		for key, field2 := range map[string]int{testKey1: 1, testKey2: 2} {
+			err = kvs.Read(key, &value)
+			if err != nil || value.Field2 != field2 {
+				t.Errorf("Read of %v returned %+v err:%v, expected Field2:%v", key, value, err, field2)
+			}
+		}
+
+		if lock {
+			if err = kvs.Unlock(false); err != nil {
+				t.Fatalf("Failed to unlock store %v", err)
+			}
+		}
+	}
+
+	// Values are persisted.
+	kvs, _ = NewBoltStore(fileName)
+	err = kvs.Read(testKey2, &value)
+	if err != nil || value.Field2 != 2 {
+		t.Errorf("Read from new store returned %+v err:%v", value, err)
+	}
+}
+
+// Tests that a new bolt store imports an existing JSON store once.
+func TestBoltStoreImportsJsonStore(t *testing.T) {
+	var value testType1
+
+	jsonFileName := testStorePath + jsonExtension
+	boltFileName := testStorePath + boltExtension
+
+	defer removeStoreFiles(jsonFileName, DefaultBackupCount)
+	defer os.Remove(boltFileName)
+	defer os.Remove(boltFileName + boltDigestExtension)
+
+	if storeType := DetectStoreType(testStorePath); storeType != StoreTypeJson {
+		t.Errorf("DetectStoreType returned %v without a database", storeType)
+	}
+
+	kvs, err := NewStore(StoreTypeJson, testStorePath)
+	if err != nil {
+		t.Fatalf("Failed to create JSON store %v", err)
+	}
+
+	kvs.Write(testKey1, &testType1{"json", 1})
+	kvs.Write(testKey2, &testType1{"json", 2})
+
+	// The bolt store starts with the state of the JSON store.
+	kvs, err = NewStore(StoreTypeBolt, testStorePath)
+	if err != nil {
+		t.Fatalf("Failed to create bolt store %v", err)
+	}
+
+	for key, field2 := range map[string]int{testKey1: 1, testKey2: 2} {
+		err = kvs.Read(key, &value)
+		if err != nil || value != (testType1{"json", field2}) {
+			t.Errorf("Read of imported %v returned %+v err:%v", key, value, err)
+		}
+	}
+
+	if _, err = os.Stat(boltFileName + tempExtension); !os.IsNotExist(err) {
+		t.Errorf("Temporary database was left behind, err:%v", err)
+	}
+
+	if storeType := DetectStoreType(testStorePath); storeType != StoreTypeBolt {
+		t.Errorf("DetectStoreType returned %v with a database", storeType)
+	}
+
+	// Later changes to the JSON store are not imported again.
+	kvs.Write(testKey1, &testType1{"bolt", 1})
+
+	jsonStore, _ := NewStore(StoreTypeJson, testStorePath)
+	jsonStore.Write(testKey1, &testType1{"json", 3})
+
+	kvs, _ = NewStore(StoreTypeBolt, testStorePath)
+	err = kvs.Read(testKey1, &value)
+	if err != nil || value != (testType1{"bolt", 1}) {
+		t.Errorf("Read after second import returned %+v err:%v", value, err)
+	}
+
+	if _, err = NewStore("xml", testStorePath); err != ErrInvalidStoreType {
+		t.Errorf("NewStore with invalid type returned err:%v", err)
+	}
+}
@@ -5,7 +5,10 @@ package store
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -17,19 +20,20 @@ const (
 	// Default file name for backing persistent store.
 	defaultFileName = "azure-container-networking.json"
 
-	// Extension added to the file name for lock.
-	lockExtension = ".lock"
+	// Extension added to the file name for the temporary file written before it replaces the store.
+	tempExtension = ".tmp"
 
-	// Maximum number of retries before failing a lock call.
-	lockMaxRetries = 200
+	// Extension added to the file name for backups, followed by the backup generation.
+	backupExtension = ".bak."
 
-	// Delay between lock retries.
-	lockRetryDelay = 100 * time.Millisecond
+	// Default number of backup generations kept.
+	DefaultBackupCount = 2
 )
 
 // jsonFileStore is an implementation of KeyValueStore using a local JSON file.
 type jsonFileStore struct {
 	fileName string
+	backups  int
 	data     map[string]*json.RawMessage
 	inSync   bool
 	locked   bool
@@ -38,65 +42,183 @@ type jsonFileStore struct {
 
 // NewJsonFileStore creates a new jsonFileStore object, accessed as a KeyValueStore.
 func NewJsonFileStore(fileName string) (KeyValueStore, error) {
+	return NewJsonFileStoreWithBackups(fileName, DefaultBackupCount)
+}
+
+// NewJsonFileStoreWithBackups creates a new jsonFileStore object that keeps the given number
+// of backup generations of the file, accessed as a KeyValueStore.
+func NewJsonFileStoreWithBackups(fileName string, backups int) (KeyValueStore, error) {
 	if fileName == "" {
 		fileName = defaultFileName
 	}
 
+	if backups < 0 {
+		return nil, fmt.Errorf("Invalid backup count %v", backups)
+	}
+
 	kvs := &jsonFileStore{
 		fileName: fileName,
+		backups:  backups,
 		data:     make(map[string]*json.RawMessage),
 	}
 
 	return kvs, nil
 }
 
+// Returns the name of the backup file of the given generation.
+func (kvs *jsonFileStore) backupFileName(generation int) string {
+	return kvs.fileName + backupExtension + strconv.Itoa(generation)
+}
+
+// Decodes the contents of a store file.
+func decodeFile(fileName string) (map[string]*json.RawMessage, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make(map[string]*json.RawMessage)
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ImportJsonFileStore copies all key value pairs persisted by a JSON file store
+// to another store in a single transaction.
+func ImportJsonFileStore(fileName string, kvs KeyValueStore) error {
+	src := &jsonFileStore{
+		fileName: fileName,
+		backups:  DefaultBackupCount,
+		data:     make(map[string]*json.RawMessage),
+	}
+
+	if err := src.load(); err != nil {
+		if err == ErrKeyNotFound {
+			// Nothing to import.
+			return nil
+		}
+		return err
+	}
+
+	return Update(kvs, func(tx Transaction) error {
+		for key, raw := range src.data {
+			if err := tx.Write(key, raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Read restores the value for the given key from persistent store.
 func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 	kvs.Mutex.Lock()
 	defer kvs.Mutex.Unlock()
 
-	// Read contents from file if memory is not in sync.
+	if err := kvs.load(); err != nil {
+		return err
+	}
+
+	raw, ok := kvs.data[key]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	return json.Unmarshal(*raw, value)
+}
+
+// Lock-free load for internal callers.
+// Reads contents from file if memory is not in sync.
+func (kvs *jsonFileStore) load() error {
 	if !kvs.inSync {
 		// Open and parse the file if it exists.
-		file, err := os.Open(kvs.fileName)
+		data, err := decodeFile(kvs.fileName)
 		if err != nil {
 			if os.IsNotExist(err) {
 				return ErrKeyNotFound
 			}
-			return err
-		}
-		defer file.Close()
 
-		// Decode to raw JSON messages.
-		if err := json.NewDecoder(file).Decode(&kvs.data); err != nil {
-			return err
+			// Fall back to the newest valid backup if the file is corrupt.
+			log.Printf("[store] Failed to read %v, err:%v.", kvs.fileName, err)
+
+			for generation := 1; generation <= kvs.backups && data == nil; generation++ {
+				data, _ = decodeFile(kvs.backupFileName(generation))
+				if data != nil {
+					log.Printf("[store] Restored from backup %v.", kvs.backupFileName(generation))
+				}
+			}
+
+			if data == nil {
+				return err
+			}
 		}
 
+		kvs.data = data
 		kvs.inSync = true
 	}
 
-	raw, ok := kvs.data[key]
-	if !ok {
-		return ErrKeyNotFound
-	}
-
-	return json.Unmarshal(*raw, value)
+	return nil
 }
 
 // Write saves the given key value pair to persistent store.
 func (kvs *jsonFileStore) Write(key string, value interface{}) error {
-	kvs.Mutex.Lock()
-	defer kvs.Mutex.Unlock()
-
 	var raw json.RawMessage
 	raw, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	kvs.data[key] = &raw
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.apply(map[string]*json.RawMessage{key: &raw})
+}
 
-	return kvs.flush()
+// BeginTransaction starts a new transaction on the store.
+func (kvs *jsonFileStore) BeginTransaction() Transaction {
+	return newTransaction(kvs.Read, kvs.commit)
+}
+
+// Saves the writes of a transaction to persistent store.
+func (kvs *jsonFileStore) commit(writes map[string]*json.RawMessage) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.apply(writes)
+}
+
+// Lock-free apply for internal callers.
+// Sets the given key value pairs and flushes them in a single write. If the flush fails,
+// the previous values are restored, so that memory does not diverge from persistent store.
+func (kvs *jsonFileStore) apply(writes map[string]*json.RawMessage) error {
+	previous := make(map[string]*json.RawMessage)
+	var added []string
+
+	for key, raw := range writes {
+		if prev, ok := kvs.data[key]; ok {
+			previous[key] = prev
+		} else {
+			added = append(added, key)
+		}
+
+		kvs.data[key] = raw
+	}
+
+	err := kvs.flush()
+	if err != nil {
+		for key, raw := range previous {
+			kvs.data[key] = raw
+		}
+
+		for _, key := range added {
+			delete(kvs.data, key)
+		}
+	}
+
+	return err
 }
 
 // Flush commits in-memory state to persistent store.
@@ -108,74 +230,160 @@ func (kvs *jsonFileStore) Flush() error {
 }
 
 // Lock-free flush for internal callers.
+// The store is written to a temporary file that atomically replaces the store file once it
+// is fully written to disk, so that a crash or a full disk never leaves a partially written store.
 func (kvs *jsonFileStore) flush() error {
-	file, err := os.Create(kvs.fileName)
+	buf, err := json.MarshalIndent(&kvs.data, "", "\t")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	buf, err := json.MarshalIndent(&kvs.data, "", "\t")
+	tempName := kvs.fileName + tempExtension
+
+	file, err := os.Create(tempName)
 	if err != nil {
 		return err
 	}
 
-	if _, err := file.Write(buf); err != nil {
+	_, err = file.Write(buf)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tempName)
 		return err
 	}
-	return nil
-}
 
-// Lock locks the store for exclusive access.
-func (kvs *jsonFileStore) Lock(block bool) error {
-	kvs.Mutex.Lock()
-	defer kvs.Mutex.Unlock()
+	// Keep the current store file as the newest backup.
+	if err = kvs.rotateBackups(); err != nil {
+		log.Printf("[store] Failed to back up %v, err:%v.", kvs.fileName, err)
+	}
 
-	if kvs.locked {
-		return ErrStoreLocked
+	if err = os.Rename(tempName, kvs.fileName); err != nil {
+		os.Remove(tempName)
+		return err
 	}
 
-	var lockFile *os.File
-	var err error
-	lockName := kvs.fileName + lockExtension
-	lockPerm := os.FileMode(0664) + os.FileMode(os.ModeExclusive)
+	syncDir(filepath.Dir(kvs.fileName))
 
-	// Try to acquire the lock file.
-	var lockRetryCount uint
-	var modTimeCur time.Time
-	var modTimePrev time.Time
-	for lockRetryCount < lockMaxRetries {
-		lockFile, err = os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_RDWR, lockPerm)
-		if err == nil {
-			break
-		}
+	return nil
+}
+
+// Shifts the backup generations and makes the current store file the newest backup.
+func (kvs *jsonFileStore) rotateBackups() error {
+	if kvs.backups == 0 {
+		return nil
+	}
 
-		if !block {
-			return ErrNonBlockingLockIsAlreadyLocked
+	if _, err := os.Stat(kvs.fileName); err != nil {
+		if os.IsNotExist(err) {
+			return nil
 		}
+		return err
+	}
 
-		// Reset the lock retry count if the timestamp for the lock file changes.
-		if fileInfo, err := os.Stat(lockName); err == nil {
-			modTimeCur = fileInfo.ModTime()
-			if !modTimeCur.Equal(modTimePrev) {
-				lockRetryCount = 0
-			}
-			modTimePrev = modTimeCur
+	for generation := kvs.backups - 1; generation >= 1; generation-- {
+		err := os.Rename(kvs.backupFileName(generation), kvs.backupFileName(generation+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
 		}
+	}
+
+	// Link the store file to its backup, so that the store file exists at all times.
+	backupName := kvs.backupFileName(1)
+	os.Remove(backupName)
+
+	if err := os.Link(kvs.fileName, backupName); err != nil {
+		return copyFile(kvs.fileName, backupName)
+	}
+
+	return nil
+}
 
-		time.Sleep(lockRetryDelay)
+// Copies the contents of a file to a new file.
+func copyFile(srcName string, dstName string) error {
+	src, err := os.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-		lockRetryCount++
+	dst, err := os.Create(dstName)
+	if err != nil {
+		return err
 	}
 
-	if lockRetryCount == lockMaxRetries {
-		return ErrTimeoutLockingStore
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
 	}
 
-	defer lockFile.Close()
+	return err
+}
 
-	// Write the process ID for easy identification.
-	if _, err = lockFile.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+// Flushes a directory to disk, so that renames in it are durable.
+// Not all platforms support syncing directories, so errors are ignored.
+func syncDir(dirName string) {
+	dir, err := os.Open(dirName)
+	if err != nil {
+		return
+	}
+
+	dir.Sync()
+	dir.Close()
+}
+
+// Watch calls onChange with the keys that changed each time the persistent store is rewritten,
+// including by other processes. Returns a function that stops watching.
+func (kvs *jsonFileStore) Watch(onChange func(keys []string)) (func(), error) {
+	return watchStore(kvs.fileName, kvs.readAll, onChange)
+}
+
+// Reads all key value pairs from the persistent store file for watchers.
+func (kvs *jsonFileStore) readAll() (map[string][]byte, error) {
+	values := make(map[string][]byte)
+
+	data, err := decodeFile(kvs.fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return nil, err
+	}
+
+	// Reload the in-memory copy on the next read, unless this store is the only writer.
+	kvs.Mutex.Lock()
+	if !kvs.locked {
+		kvs.inSync = false
+	}
+	kvs.Mutex.Unlock()
+
+	for key, raw := range data {
+		if raw != nil {
+			values[key] = *raw
+		} else {
+			values[key] = nil
+		}
+	}
+
+	return values, nil
+}
+
+// Lock locks the store for exclusive access.
+func (kvs *jsonFileStore) Lock(block bool) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if kvs.locked {
+		return ErrStoreLocked
+	}
+
+	if err := acquireLockFile(kvs.fileName+lockExtension, block); err != nil {
 		return err
 	}
 
@@ -223,21 +431,5 @@ func (kvs *jsonFileStore) GetLockFileModificationTime() (time.Time, error) {
 	kvs.Mutex.Lock()
 	defer kvs.Mutex.Unlock()
 
-	lockFileName := kvs.fileName + lockExtension
-
-	// Check if the file exists.
-	file, err := os.Open(lockFileName)
-	if err != nil {
-		return time.Time{}.UTC(), err
-	}
-
-	defer file.Close()
-
-	info, err := os.Stat(lockFileName)
-	if err != nil {
-		log.Printf("os.stat() for file %v failed: %v", lockFileName, err)
-		return time.Time{}.UTC(), err
-	}
-
-	return info.ModTime().UTC(), nil
+	return getLockFileModificationTime(kvs.fileName + lockExtension)
 }
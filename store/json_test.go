@@ -4,7 +4,10 @@
 package store
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -206,3 +209,204 @@ func TestLockingStoreGivesExclusiveAccess(t *testing.T) {
 	// Cleanup.
 	os.Remove(testFileName)
 }
+
+// Tests that writes keep backup generations of the store file and leave no temporary file.
+func TestWritesRotateBackups(t *testing.T) {
+	defer removeStoreFiles(testFileName, 2)
+
+	kvs, err := NewJsonFileStoreWithBackups(testFileName, 2)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v\n", err)
+	}
+
+	// Write three generations of the same key.
+	for i := 1; i <= 3; i++ {
+		err = kvs.Write(testKey1, &testType1{"test", i})
+		if err != nil {
+			t.Fatalf("Failed to write to store %v", err)
+		}
+	}
+
+	if _, err = os.Stat(testFileName + tempExtension); !os.IsNotExist(err) {
+		t.Errorf("Temporary file was left behind, err:%v", err)
+	}
+
+	// The newest backup holds the previous generation.
+	expected := map[string]int{
+		testFileName:                         3,
+		testFileName + backupExtension + "1": 2,
+		testFileName + backupExtension + "2": 1,
+	}
+
+	for fileName, field2 := range expected {
+		var value testType1
+
+		kvs, _ = NewJsonFileStoreWithBackups(fileName, 0)
+		err = kvs.Read(testKey1, &value)
+		if err != nil || value.Field2 != field2 {
+			t.Errorf("File %v has value %+v err:%v, expected Field2:%v", fileName, value, err, field2)
+		}
+	}
+
+	if _, err = os.Stat(testFileName + backupExtension + "3"); !os.IsNotExist(err) {
+		t.Errorf("More backups were kept than configured, err:%v", err)
+	}
+}
+
+// Tests that reads fall back to the newest valid backup when the store file is corrupt.
+func TestReadFallsBackToValidBackup(t *testing.T) {
+	var value testType1
+
+	defer removeStoreFiles(testFileName, 2)
+
+	kvs, err := NewJsonFileStoreWithBackups(testFileName, 2)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v\n", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = kvs.Write(testKey1, &testType1{"test", i})
+		if err != nil {
+			t.Fatalf("Failed to write to store %v", err)
+		}
+	}
+
+	// Corrupt the store file and the newest backup.
+	ioutil.WriteFile(testFileName, []byte(`{"key1":{"Fie`), 0644)
+	ioutil.WriteFile(testFileName+backupExtension+"1", []byte{}, 0644)
+
+	kvs, _ = NewJsonFileStoreWithBackups(testFileName, 2)
+	err = kvs.Read(testKey1, &value)
+	if err != nil || value.Field2 != 1 {
+		t.Errorf("Read from backup returned %+v err:%v", value, err)
+	}
+
+	// Without valid backups, the decoding error is returned.
+	kvs, _ = NewJsonFileStoreWithBackups(testFileName, 1)
+	err = kvs.Read(testKey1, &value)
+	if err == nil {
+		t.Errorf("Read of a corrupt store succeeded")
+	}
+}
+
+// Tests that transactions read their own writes and persist all writes together on commit.
+func TestTransactionCommitsAllWrites(t *testing.T) {
+	var value testType1
+
+	defer removeStoreFiles(testFileName, 0)
+
+	kvs, err := NewJsonFileStoreWithBackups(testFileName, 0)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v\n", err)
+	}
+
+	err = kvs.Write(testKey1, &testType1{"test", 1})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	tx := kvs.BeginTransaction()
+	tx.Write(testKey1, &testType1{"test", 2})
+	tx.Write(testKey2, &testType1{"test", 3})
+
+	// The transaction observes its own writes.
+	err = tx.Read(testKey1, &value)
+	if err != nil || value.Field2 != 2 {
+		t.Errorf("Transaction read returned %+v err:%v", value, err)
+	}
+
+	// Uncommitted writes are not visible in the store.
+	err = kvs.Read(testKey1, &value)
+	if err != nil || value.Field2 != 1 {
+		t.Errorf("Store read returned uncommitted value %+v err:%v", value, err)
+	}
+
+	err = kvs.Read(testKey2, &value)
+	if err != ErrKeyNotFound {
+		t.Errorf("Store read returned uncommitted key, err:%v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit transaction %v", err)
+	}
+
+	// Closed transactions cannot be used again.
+	if err = tx.Write(testKey1, &value); err != ErrTransactionClosed {
+		t.Errorf("Write after commit returned err:%v", err)
+	}
+
+	// All writes are persisted.
+	kvs, _ = NewJsonFileStoreWithBackups(testFileName, 0)
+	for key, field2 := range map[string]int{testKey1: 2, testKey2: 3} {
+		err = kvs.Read(key, &value)
+		if err != nil || value.Field2 != field2 {
+			t.Errorf("Read of %v returned %+v err:%v, expected Field2:%v", key, value, err, field2)
+		}
+	}
+}
+
+// Tests that transactions are rolled back on error and leave the store unchanged.
+func TestTransactionRollback(t *testing.T) {
+	var value testType1
+
+	tempName := testFileName + tempExtension
+
+	defer removeStoreFiles(testFileName, 0)
+	defer os.Remove(tempName)
+
+	kvs, err := NewJsonFileStoreWithBackups(testFileName, 0)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v\n", err)
+	}
+
+	err = kvs.Write(testKey1, &testType1{"test", 1})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	// Errors returned by the update discard its writes.
+	updateErr := fmt.Errorf("update failed")
+	err = Update(kvs, func(tx Transaction) error {
+		tx.Write(testKey1, &testType1{"test", 2})
+		tx.Write(testKey2, &testType1{"test", 2})
+		return updateErr
+	})
+
+	if err != updateErr {
+		t.Errorf("Update returned err:%v", err)
+	}
+
+	// Failed flushes restore the previous values in memory.
+	// A directory in place of the temporary file makes the flush fail.
+	os.Mkdir(tempName, 0755)
+
+	err = Update(kvs, func(tx Transaction) error {
+		tx.Write(testKey1, &testType1{"test", 3})
+		return tx.Write(testKey2, &testType1{"test", 3})
+	})
+
+	if err == nil {
+		t.Errorf("Update succeeded although flush failed")
+	}
+
+	os.Remove(tempName)
+
+	err = kvs.Read(testKey1, &value)
+	if err != nil || value.Field2 != 1 {
+		t.Errorf("Read after rollback returned %+v err:%v", value, err)
+	}
+
+	err = kvs.Read(testKey2, &value)
+	if err != ErrKeyNotFound {
+		t.Errorf("Read after rollback returned key, err:%v", err)
+	}
+}
+
+// Removes a test store file and its backups.
+func removeStoreFiles(fileName string, backups int) {
+	os.Remove(fileName)
+	for generation := 1; generation <= backups; generation++ {
+		os.Remove(fileName + backupExtension + strconv.Itoa(generation))
+	}
+}
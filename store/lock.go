@@ -0,0 +1,223 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Extension added to the file name for lock.
+	lockExtension = ".lock"
+
+	// Maximum number of retries before failing a lock call.
+	lockMaxRetries = 200
+
+	// Delay between lock retries.
+	lockRetryDelay = 100 * time.Millisecond
+
+	// Extension added to the lock file name for the file serializing takeovers of stale locks.
+	takeoverExtension = ".takeover"
+
+	// Age after which a takeover file is considered left behind by an exited process.
+	takeoverTimeout = 10 * time.Second
+
+	// Age after which a lock file without a valid owner record is considered stale.
+	// Owners write their record right after creating the lock file.
+	ownerWriteTimeout = 10 * time.Second
+)
+
+// lockOwner identifies the process that owns a lock file.
+type lockOwner struct {
+	Pid          int
+	StartTime    uint64
+	Hostname     string
+	PidNamespace uint64
+}
+
+// Returns the lock owner record of the current process.
+func getCurrentLockOwner() *lockOwner {
+	owner := &lockOwner{Pid: os.Getpid()}
+	owner.StartTime, _ = getProcessStartTime(owner.Pid)
+	owner.Hostname, _ = os.Hostname()
+	owner.PidNamespace, _ = getPidNamespace()
+	return owner
+}
+
+// Reads the owner record of a lock file.
+// Lock files written by earlier versions contain only the process ID, and are read without a PID namespace.
+func readLockOwner(lockName string) (*lockOwner, error) {
+	buf, err := ioutil.ReadFile(lockName)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := &lockOwner{}
+	if err = json.Unmarshal(buf, owner); err != nil {
+		owner.Pid, err = strconv.Atoi(strings.TrimSpace(string(buf)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return owner, nil
+}
+
+// Returns whether the owner of a lock file is known to have exited.
+func (owner *lockOwner) isDead() bool {
+	// Processes on other hosts cannot be checked.
+	if hostname, _ := os.Hostname(); owner.Hostname != "" && owner.Hostname != hostname {
+		return false
+	}
+
+	// Process IDs are only meaningful in the PID namespace of the owner. Processes sharing the hostname,
+	// such as host network containers, can run in other namespaces. Owners that did not record their
+	// namespace, including those of earlier versions, are therefore never considered dead.
+	namespace, err := getPidNamespace()
+	if err != nil || owner.PidNamespace == 0 || owner.PidNamespace != namespace {
+		return false
+	}
+
+	startTime, err := getProcessStartTime(owner.Pid)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+
+	// A different start time means that the process ID was reused by another process.
+	return owner.StartTime != 0 && owner.StartTime != startTime
+}
+
+// Returns whether a lock file is held by a process that has exited.
+func isLockFileStale(lockName string) (bool, *lockOwner) {
+	owner, err := readLockOwner(lockName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		// The owner exited between creating the lock file and writing its record.
+		info, err := os.Stat(lockName)
+		return err == nil && time.Since(info.ModTime()) > ownerWriteTimeout, nil
+	}
+
+	return owner.isDead(), owner
+}
+
+// Removes a lock file if its owner has exited, so that waiters can take over the lock.
+// Takeovers are serialized by a second lock file, and the owner is checked while holding it,
+// so that a lock acquired by another waiter in the meantime is never removed.
+// Returns whether the lock file was removed.
+func removeStaleLockFile(lockName string) bool {
+	takeoverName := lockName + takeoverExtension
+
+	file, err := os.OpenFile(takeoverName, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0664)
+	if err != nil {
+		// Recover from a process that exited in the middle of a takeover.
+		if info, err := os.Stat(takeoverName); err == nil && time.Since(info.ModTime()) > takeoverTimeout {
+			os.Remove(takeoverName)
+		}
+		return false
+	}
+
+	file.Close()
+	defer os.Remove(takeoverName)
+
+	stale, owner := isLockFileStale(lockName)
+	if !stale {
+		return false
+	}
+
+	if err = os.Remove(lockName); err != nil {
+		log.Printf("[store] Failed to remove stale lock file %v, err:%v.", lockName, err)
+		return false
+	}
+
+	log.Printf("[store] Removed lock file %v of exited owner %+v.", lockName, owner)
+
+	return true
+}
+
+// Acquires a lock file shared by all processes accessing a store.
+func acquireLockFile(lockName string, block bool) error {
+	var lockFile *os.File
+	var err error
+	lockPerm := os.FileMode(0664) + os.FileMode(os.ModeExclusive)
+
+	// Try to acquire the lock file.
+	var lockRetryCount uint
+	var modTimeCur time.Time
+	var modTimePrev time.Time
+	for lockRetryCount < lockMaxRetries {
+		lockFile, err = os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_RDWR, lockPerm)
+		if err == nil {
+			break
+		}
+
+		// Take over the lock immediately if its owner has exited.
+		if removeStaleLockFile(lockName) {
+			continue
+		}
+
+		if !block {
+			return ErrNonBlockingLockIsAlreadyLocked
+		}
+
+		// Reset the lock retry count if the timestamp for the lock file changes.
+		if fileInfo, err := os.Stat(lockName); err == nil {
+			modTimeCur = fileInfo.ModTime()
+			if !modTimeCur.Equal(modTimePrev) {
+				lockRetryCount = 0
+			}
+			modTimePrev = modTimeCur
+		}
+
+		time.Sleep(lockRetryDelay)
+
+		lockRetryCount++
+	}
+
+	if lockRetryCount == lockMaxRetries {
+		return ErrTimeoutLockingStore
+	}
+
+	defer lockFile.Close()
+
+	// Record the owner, so that waiters can detect if it exits without releasing the lock.
+	buf, err := json.Marshal(getCurrentLockOwner())
+	if err != nil {
+		return err
+	}
+
+	if _, err = lockFile.Write(buf); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the modification time of a lock file.
+func getLockFileModificationTime(lockFileName string) (time.Time, error) {
+	// Check if the file exists.
+	file, err := os.Open(lockFileName)
+	if err != nil {
+		return time.Time{}.UTC(), err
+	}
+
+	defer file.Close()
+
+	info, err := os.Stat(lockFileName)
+	if err != nil {
+		log.Printf("os.stat() for file %v failed: %v", lockFileName, err)
+		return time.Time{}.UTC(), err
+	}
+
+	return info.ModTime().UTC(), nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Returns the start time of a process in clock ticks since boot.
+// Returns an error satisfying os.IsNotExist if the process does not exist or has exited.
+func getProcessStartTime(pid int) (uint64, error) {
+	buf, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name can contain spaces and parentheses, so fields are counted from its end.
+	stat := string(buf)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+
+	// The state is the third field, and the start time the twenty-second.
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("Invalid process stat %v", stat)
+	}
+
+	// Zombie processes have exited, but are not yet reaped by their parent.
+	if fields[0] == "Z" {
+		return 0, os.ErrNotExist
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// Returns the identity of the PID namespace of the current process.
+func getPidNamespace() (uint64, error) {
+	info, err := os.Stat("/proc/self/ns/pid")
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Sys().(*syscall.Stat_t).Ino, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Returns the ID of a process that has exited.
+func getExitedProcessId(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run process, err:%v", err)
+	}
+
+	return cmd.Process.Pid
+}
+
+// Tests that locks are taken over from owners that have exited, and only from them.
+func TestLockTakeoverFromExitedOwner(t *testing.T) {
+	lockName := testFileName + lockExtension
+	defer os.Remove(lockName)
+
+	self := getCurrentLockOwner()
+	if self.PidNamespace == 0 {
+		t.Skip("Process ID namespaces are not supported")
+	}
+
+	exitedPid := getExitedProcessId(t)
+	namespace := strconv.FormatUint(self.PidNamespace, 10)
+
+	tests := []struct {
+		name     string
+		contents string
+		stale    bool
+	}{
+		{"live owner", "", false},
+		{"exited owner", `{"Pid":` + strconv.Itoa(exitedPid) + `,"PidNamespace":` + namespace + `}`, true},
+		{"reused process ID", `{"Pid":` + strconv.Itoa(self.Pid) + `,"StartTime":1,"PidNamespace":` + namespace + `}`, true},
+		{"owner on another host", `{"Pid":` + strconv.Itoa(exitedPid) + `,"Hostname":"other-` + self.Hostname + `","PidNamespace":` + namespace + `}`, false},
+		{"owner in another PID namespace", `{"Pid":` + strconv.Itoa(exitedPid) + `,"PidNamespace":` + namespace + `1}`, false},
+		{"owner without PID namespace", `{"Pid":` + strconv.Itoa(exitedPid) + `}`, false},
+		{"earlier version with live owner", strconv.Itoa(self.Pid), false},
+		{"earlier version with exited owner", strconv.Itoa(exitedPid), false},
+		{"owner record not written yet", "", false},
+	}
+
+	for _, test := range tests {
+		os.Remove(lockName)
+
+		kvs, _ := NewJsonFileStore(testFileName)
+
+		if test.name == "live owner" {
+			// Another store in this process owns the lock.
+			owner, _ := NewJsonFileStore(testFileName)
+			if err := owner.Lock(false); err != nil {
+				t.Fatalf("Failed to lock store, err:%v", err)
+			}
+		} else {
+			ioutil.WriteFile(lockName, []byte(test.contents), 0664)
+		}
+
+		err := kvs.Lock(false)
+		if test.stale && err != nil {
+			t.Errorf("Failed to take over lock with %v, err:%v", test.name, err)
+		}
+
+		if !test.stale && err != ErrNonBlockingLockIsAlreadyLocked {
+			t.Errorf("Lock with %v returned err:%v", test.name, err)
+		}
+
+		if _, err = os.Stat(lockName + takeoverExtension); !os.IsNotExist(err) {
+			t.Errorf("Takeover file was left behind with %v, err:%v", test.name, err)
+		}
+	}
+
+	// Lock files without an owner record are stale after a while.
+	old := time.Now().Add(-2 * ownerWriteTimeout)
+	os.Chtimes(lockName, old, old)
+
+	kvs, _ := NewJsonFileStore(testFileName)
+	if err := kvs.Lock(false); err != nil {
+		t.Errorf("Failed to take over lock without owner record, err:%v", err)
+	}
+
+	// The new owner is recorded in the lock file.
+	buf, _ := ioutil.ReadFile(lockName)
+
+	var owner lockOwner
+	err := json.Unmarshal(buf, &owner)
+	if err != nil || owner != *self || owner.StartTime == 0 {
+		t.Errorf("Lock file has owner %+v err:%v, expected %+v", owner, err, self)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// Exit code of a process that is still running.
+	stillActive = 259
+)
+
+// Returns the creation time of a process in nanoseconds since the Unix epoch.
+// Returns an error satisfying os.IsNotExist if the process does not exist or has exited.
+func getProcessStartTime(pid int) (uint64, error) {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		if err == windows.ERROR_INVALID_PARAMETER {
+			return 0, os.ErrNotExist
+		}
+		return 0, err
+	}
+	defer windows.CloseHandle(handle)
+
+	var exitCode uint32
+	if err = windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return 0, err
+	}
+
+	if exitCode != stillActive {
+		return 0, os.ErrNotExist
+	}
+
+	var creationTime, exitTime, kernelTime, userTime windows.Filetime
+	err = windows.GetProcessTimes(handle, &creationTime, &exitTime, &kernelTime, &userTime)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(creationTime.Nanoseconds()), nil
+}
+
+// Returns the identity of the PID namespace of the current process.
+// Windows does not expose process ID namespaces, so zero is returned and locks are never taken over.
+func getPidNamespace() (uint64, error) {
+	return 0, nil
+}
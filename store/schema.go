@@ -0,0 +1,146 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Name of the field holding the schema version of a persisted document.
+	SchemaVersionField = "SchemaVersion"
+)
+
+var (
+	// Errors returned by Schema methods.
+	ErrMigrationNotFound  = fmt.Errorf("no migration registered for schema version")
+	ErrInvalidSchemaField = fmt.Errorf("persisted state has an invalid schema version")
+)
+
+// SchemaDowngradeError is returned when a persisted document has a newer schema version
+// than the one supported, for example after the binary has been rolled back.
+type SchemaDowngradeError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SchemaDowngradeError) Error() string {
+	return fmt.Sprintf("persisted state has a newer schema version: %d, supported: %d", e.Version, e.Supported)
+}
+
+// IsSchemaDowngrade returns whether an error was caused by a persisted document with a newer schema version.
+func IsSchemaDowngrade(err error) bool {
+	_, ok := err.(*SchemaDowngradeError)
+	return ok
+}
+
+// MigrationFunc upgrades a persisted document by one schema version.
+// The document is the generic JSON decoding of the persisted object, with numbers as json.Number.
+type MigrationFunc func(doc map[string]interface{}) error
+
+// Schema describes the current schema version of a persisted object
+// and the migrations that upgrade documents written by older versions.
+// Documents written before schema versioning was introduced have version 0,
+// and are read as version 1 unless a migration from version 0 is registered.
+type Schema struct {
+	Version    int
+	migrations map[int]MigrationFunc
+}
+
+// NewSchema creates a new schema at the given version.
+func NewSchema(version int) *Schema {
+	return &Schema{
+		Version:    version,
+		migrations: make(map[int]MigrationFunc),
+	}
+}
+
+// RegisterMigration registers a migration that upgrades documents from the given version to the next.
+func (s *Schema) RegisterMigration(fromVersion int, migrate MigrationFunc) {
+	s.migrations[fromVersion] = migrate
+}
+
+// Read reads the value of the given key from the store, upgrading it to the current schema version.
+// Documents with a newer schema version are refused, as their contents cannot be interpreted safely.
+func (s *Schema) Read(kvs KeyValueStore, key string, value interface{}) error {
+	var raw json.RawMessage
+
+	err := kvs.Read(key, &raw)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := s.Migrate(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(migrated, value)
+}
+
+// Migrate upgrades a JSON document to the current schema version.
+func (s *Schema) Migrate(raw []byte) ([]byte, error) {
+	var doc map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := getSchemaVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > s.Version {
+		return nil, &SchemaDowngradeError{Version: version, Supported: s.Version}
+	}
+
+	if version == s.Version {
+		return raw, nil
+	}
+
+	for ; version < s.Version; version++ {
+		migrate := s.migrations[version]
+		if migrate == nil {
+			// Unversioned documents are compatible with version 1 unless a migration says otherwise.
+			if version != 0 {
+				return nil, fmt.Errorf("%v %d", ErrMigrationNotFound, version)
+			}
+		} else {
+			err = migrate(doc)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to migrate schema version %d, err:%v", version, err)
+			}
+		}
+
+		doc[SchemaVersionField] = version + 1
+	}
+
+	return json.Marshal(doc)
+}
+
+// Returns the schema version of a document.
+func getSchemaVersion(doc map[string]interface{}) (int, error) {
+	value, ok := doc[SchemaVersionField]
+	if !ok || value == nil {
+		return 0, nil
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, ErrInvalidSchemaField
+	}
+
+	version, err := number.Int64()
+	if err != nil || version < 0 {
+		return 0, ErrInvalidSchemaField
+	}
+
+	return int(version), nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Type for testing schema migrations.
+type testSchemaType struct {
+	SchemaVersion int
+	Name          string
+	Count         int64
+}
+
+// Creates a test schema that renames Field1 to Name in version 1 and doubles Count in version 2.
+func createTestSchema() *Schema {
+	schema := NewSchema(2)
+
+	schema.RegisterMigration(0, func(doc map[string]interface{}) error {
+		doc["Name"] = doc["Field1"]
+		delete(doc, "Field1")
+		return nil
+	})
+
+	schema.RegisterMigration(1, func(doc map[string]interface{}) error {
+		count, err := doc["Count"].(json.Number).Int64()
+		doc["Count"] = count * 2
+		return err
+	})
+
+	return schema
+}
+
+// Tests that older documents are upgraded to the current schema version on read.
+func TestSchemaMigratesOlderDocuments(t *testing.T) {
+	defer removeStoreFiles(testFileName, DefaultBackupCount)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v", err)
+	}
+
+	schema := createTestSchema()
+
+	tests := []struct {
+		doc      interface{}
+		expected testSchemaType
+	}{
+		// Documents without a schema version run all migrations.
+		{
+			map[string]interface{}{"Field1": "a", "Count": 9007199254740993},
+			testSchemaType{2, "a", 18014398509481986},
+		},
+		{
+			map[string]interface{}{"SchemaVersion": 1, "Name": "b", "Count": 3},
+			testSchemaType{2, "b", 6},
+		},
+		// Documents at the current version are read as is.
+		{
+			testSchemaType{2, "c", 5},
+			testSchemaType{2, "c", 5},
+		},
+	}
+
+	for _, test := range tests {
+		err = kvs.Write(testKey1, test.doc)
+		if err != nil {
+			t.Fatalf("Failed to write to store %v", err)
+		}
+
+		var actual testSchemaType
+		err = schema.Read(kvs, testKey1, &actual)
+		if err != nil {
+			t.Fatalf("Failed to read %+v, err:%v", test.doc, err)
+		}
+
+		if actual != test.expected {
+			t.Errorf("Read %+v as %+v, expected %+v", test.doc, actual, test.expected)
+		}
+	}
+}
+
+// Tests that unversioned documents are read as version 1 when no migration from version 0 is registered.
+func TestSchemaReadsUnversionedDocumentsAsVersion1(t *testing.T) {
+	defer removeStoreFiles(testFileName, DefaultBackupCount)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v", err)
+	}
+
+	err = kvs.Write(testKey1, map[string]interface{}{"Name": "a", "Count": 1})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	var actual testSchemaType
+	err = NewSchema(1).Read(kvs, testKey1, &actual)
+	if err != nil {
+		t.Fatalf("Failed to read unversioned document, err:%v", err)
+	}
+
+	expected := testSchemaType{1, "a", 1}
+	if actual != expected {
+		t.Errorf("Read unversioned document as %+v, expected %+v", actual, expected)
+	}
+}
+
+// Tests that documents with a newer schema version or missing migrations are refused.
+func TestSchemaRefusesUnsupportedDocuments(t *testing.T) {
+	defer removeStoreFiles(testFileName, DefaultBackupCount)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create KeyValueStore %v", err)
+	}
+
+	var actual testSchemaType
+
+	// Downgrades are refused.
+	err = kvs.Write(testKey1, testSchemaType{3, "a", 1})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	err = createTestSchema().Read(kvs, testKey1, &actual)
+	if !IsSchemaDowngrade(err) {
+		t.Errorf("Read of newer schema version returned err:%v", err)
+	}
+
+	if IsSchemaDowngrade(ErrMigrationNotFound) {
+		t.Errorf("IsSchemaDowngrade matched an unrelated error")
+	}
+
+	// Gaps in the migration chain are refused.
+	schema := NewSchema(2)
+	schema.RegisterMigration(0, func(doc map[string]interface{}) error { return nil })
+
+	err = kvs.Write(testKey1, map[string]interface{}{"Name": "a"})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	err = schema.Read(kvs, testKey1, &actual)
+	if err == nil || !strings.HasPrefix(err.Error(), ErrMigrationNotFound.Error()) {
+		t.Errorf("Read without migration returned err:%v", err)
+	}
+
+	// Invalid schema versions are refused.
+	err = kvs.Write(testKey1, map[string]interface{}{"SchemaVersion": "1"})
+	if err != nil {
+		t.Fatalf("Failed to write to store %v", err)
+	}
+
+	err = schema.Read(kvs, testKey1, &actual)
+	if err != ErrInvalidSchemaField {
+		t.Errorf("Read of invalid schema version returned err:%v", err)
+	}
+
+	// Missing keys are reported as usual.
+	err = schema.Read(kvs, testKey2, &actual)
+	if err != ErrKeyNotFound {
+		t.Errorf("Read of missing key returned err:%v", err)
+	}
+}
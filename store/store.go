@@ -5,9 +5,20 @@ package store
 
 import (
 	"fmt"
+	"os"
 	"time"
 )
 
+const (
+	// Types of persistent store.
+	StoreTypeJson = "json"
+	StoreTypeBolt = "bolt"
+
+	// Extensions added to the store path for each type of persistent store.
+	jsonExtension = ".json"
+	boltExtension = ".db"
+)
+
 // KeyValueStore represents a persistent store of (key,value) pairs.
 type KeyValueStore interface {
 	Read(key string, value interface{}) error
@@ -17,6 +28,17 @@ type KeyValueStore interface {
 	Unlock(forceUnlock bool) error
 	GetModificationTime() (time.Time, error)
 	GetLockFileModificationTime() (time.Time, error)
+	BeginTransaction() Transaction
+	Watch(onChange func(keys []string)) (func(), error)
+}
+
+// Transaction represents a set of writes to a KeyValueStore that are committed together.
+// Reads within a transaction observe its own uncommitted writes.
+type Transaction interface {
+	Read(key string, value interface{}) error
+	Write(key string, value interface{}) error
+	Commit() error
+	Rollback()
 }
 
 var (
@@ -26,4 +48,49 @@ var (
 	ErrStoreNotLocked                 = fmt.Errorf("store is not locked")
 	ErrTimeoutLockingStore            = fmt.Errorf("timed out locking store")
 	ErrNonBlockingLockIsAlreadyLocked = fmt.Errorf("attempted to perform non-blocking lock on an already locked store")
+	ErrTransactionClosed              = fmt.Errorf("transaction is already committed or rolled back")
+	ErrInvalidStoreType               = fmt.Errorf("invalid store type")
 )
+
+// NewStore creates a new KeyValueStore of the given type, persisted at the given path
+// with the extension of the store type. The first time a bolt store is created,
+// it imports the state of an existing JSON store at the same path.
+func NewStore(storeType string, path string) (KeyValueStore, error) {
+	switch storeType {
+	case StoreTypeJson:
+		return NewJsonFileStore(path + jsonExtension)
+
+	case StoreTypeBolt:
+		if err := importJsonFileStoreOnce(path+jsonExtension, path+boltExtension); err != nil {
+			return nil, err
+		}
+
+		return NewBoltStore(path + boltExtension)
+
+	default:
+		return nil, ErrInvalidStoreType
+	}
+}
+
+// DetectStoreType returns the type of the store persisted at the given path.
+// Bolt is returned if a bolt database exists, and JSON otherwise.
+func DetectStoreType(path string) string {
+	if _, err := os.Stat(path + boltExtension); err == nil {
+		return StoreTypeBolt
+	}
+
+	return StoreTypeJson
+}
+
+// Update runs the given function in a new transaction on the store.
+// The transaction is committed if the function succeeds, and rolled back otherwise.
+func Update(kvs KeyValueStore, update func(tx Transaction) error) error {
+	tx := kvs.BeginTransaction()
+
+	if err := update(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
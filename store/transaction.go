@@ -0,0 +1,80 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+)
+
+// transaction is a Transaction that buffers writes in memory until it is committed.
+// Reads of keys not written by the transaction are served by the store.
+type transaction struct {
+	read   func(key string, value interface{}) error
+	commit func(writes map[string]*json.RawMessage) error
+	writes map[string]*json.RawMessage
+	closed bool
+}
+
+// Creates a new transaction on a store with the given read and commit functions.
+func newTransaction(
+	read func(key string, value interface{}) error,
+	commit func(writes map[string]*json.RawMessage) error) Transaction {
+	return &transaction{
+		read:   read,
+		commit: commit,
+		writes: make(map[string]*json.RawMessage),
+	}
+}
+
+// Read restores the value for the given key, including uncommitted writes of the transaction.
+func (tx *transaction) Read(key string, value interface{}) error {
+	if tx.closed {
+		return ErrTransactionClosed
+	}
+
+	if raw, ok := tx.writes[key]; ok {
+		return json.Unmarshal(*raw, value)
+	}
+
+	return tx.read(key, value)
+}
+
+// Write buffers the given key value pair until the transaction is committed.
+func (tx *transaction) Write(key string, value interface{}) error {
+	if tx.closed {
+		return ErrTransactionClosed
+	}
+
+	var raw json.RawMessage
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	tx.writes[key] = &raw
+
+	return nil
+}
+
+// Commit saves all writes of the transaction to persistent store at once.
+// Either all or none of the writes are persisted.
+func (tx *transaction) Commit() error {
+	if tx.closed {
+		return ErrTransactionClosed
+	}
+
+	tx.closed = true
+
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	return tx.commit(tx.writes)
+}
+
+// Rollback discards all writes of the transaction.
+func (tx *transaction) Rollback() {
+	tx.closed = true
+	tx.writes = nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/watch"
+)
+
+const (
+	// Interval between modification time checks where file notifications are not supported.
+	watchPollInterval = 5 * time.Second
+)
+
+// storeWatcher reports the keys that change each time the persistent store is rewritten.
+type storeWatcher struct {
+	fileName string
+	readAll  func() (map[string][]byte, error)
+	onChange func(keys []string)
+	modTime  time.Time
+	values   map[string][]byte
+	sync.Mutex
+}
+
+// Watches the persistent store file of a store and calls onChange with the keys whose values
+// were added, changed or removed. readAll returns the values, or digests of the values, of all keys in the file.
+// Returns a function that stops watching.
+func watchStore(
+	fileName string,
+	readAll func() (map[string][]byte, error),
+	onChange func(keys []string)) (func(), error) {
+
+	w := &storeWatcher{
+		fileName: fileName,
+		readAll:  readAll,
+		onChange: onChange,
+	}
+
+	// Take the initial snapshot, so that only later changes are reported.
+	w.modTime = getModificationTime(fileName)
+
+	values, err := readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	w.values = values
+
+	stop, err := watch.File(fileName, w.refresh)
+	if err != nil {
+		log.Printf("[store] Polling %v for changes, err:%v.", fileName, err)
+		stop = w.poll(watchPollInterval)
+	}
+
+	return stop, nil
+}
+
+// Checks the modification time of the store file periodically, and refreshes it when it changes.
+// Returns a function that stops polling.
+func (w *storeWatcher) poll(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if !getModificationTime(w.fileName).Equal(w.getModTime()) {
+					w.refresh()
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Returns the modification time of the store file when it was last read.
+func (w *storeWatcher) getModTime() time.Time {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.modTime
+}
+
+// Reads the store file and reports the keys whose values differ from the last read.
+func (w *storeWatcher) refresh() {
+	w.Lock()
+	defer w.Unlock()
+
+	modTime := getModificationTime(w.fileName)
+
+	values, err := w.readAll()
+	if err != nil {
+		log.Printf("[store] Failed to read %v for watchers, err:%v.", w.fileName, err)
+		return
+	}
+
+	keys := diffValues(w.values, values)
+
+	w.modTime = modTime
+	w.values = values
+
+	if len(keys) > 0 {
+		log.Printf("[store] Keys %v changed in %v.", keys, w.fileName)
+		w.onChange(keys)
+	}
+}
+
+// Returns the modification time of a file, or the zero time if it does not exist.
+// Unlike GetModificationTime, a missing file is not logged, as stores are polled before they are created.
+func getModificationTime(fileName string) time.Time {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime().UTC()
+}
+
+// Returns the sorted keys whose values differ between two snapshots of a store.
+func diffValues(old map[string][]byte, new map[string][]byte) []string {
+	var keys []string
+
+	for key, value := range new {
+		if oldValue, ok := old[key]; !ok || !bytes.Equal(oldValue, value) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
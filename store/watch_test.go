@@ -0,0 +1,150 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Waits for the keys reported by a watcher, or returns nil after a timeout.
+func waitForChange(changes chan []string) []string {
+	select {
+	case keys := <-changes:
+		return keys
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
+
+// Tests that watchers are notified of the keys changed by writers of the same store file.
+func TestWatchReportsChangedKeys(t *testing.T) {
+	jsonFileName := testStorePath + jsonExtension
+	boltFileName := testStorePath + boltExtension
+
+	defer removeStoreFiles(jsonFileName, DefaultBackupCount)
+	defer os.Remove(boltFileName)
+	defer os.Remove(boltFileName + boltDigestExtension)
+
+	for _, storeType := range []string{StoreTypeJson, StoreTypeBolt} {
+		// The writer stands in for another process sharing the store file.
+		writer, _ := NewStore(storeType, testStorePath)
+		writer.Write(testKey1, &testType1{"test", 1})
+
+		kvs, _ := NewStore(storeType, testStorePath)
+
+		changes := make(chan []string, 10)
+		stop, err := kvs.Watch(func(keys []string) { changes <- keys })
+		if err != nil {
+			t.Fatalf("Failed to watch %v store, err:%v", storeType, err)
+		}
+
+		// Added and changed keys are reported together, unchanged keys are not.
+		err = Update(writer, func(tx Transaction) error {
+			tx.Write(testKey1, &testType1{"test", 2})
+			return tx.Write(testKey2, &testType1{"test", 2})
+		})
+
+		if err != nil {
+			t.Fatalf("Failed to update %v store, err:%v", storeType, err)
+		}
+
+		keys := waitForChange(changes)
+		if !reflect.DeepEqual(keys, []string{testKey1, testKey2}) {
+			t.Errorf("Watch of %v store reported keys %v", storeType, keys)
+		}
+
+		writer.Write(testKey2, &testType1{"test", 3})
+
+		keys = waitForChange(changes)
+		if !reflect.DeepEqual(keys, []string{testKey2}) {
+			t.Errorf("Watch of %v store reported keys %v", storeType, keys)
+		}
+
+		// Reads of the watching store return the changed values.
+		var value testType1
+		err = kvs.Read(testKey2, &value)
+		if err != nil || value.Field2 != 3 {
+			t.Errorf("Read of changed key from %v store returned %+v err:%v", storeType, value, err)
+		}
+
+		stop()
+	}
+}
+
+// Tests that watchers of a bolt store are notified of each commit while the writer keeps the database open.
+func TestWatchBoltStoreKeptOpen(t *testing.T) {
+	fileName := testStorePath + boltExtension
+	defer os.Remove(fileName)
+	defer os.Remove(fileName + boltDigestExtension)
+
+	// The writer stands in for a long-running service that holds the store lock.
+	writer, _ := NewBoltStore(fileName)
+	if err := writer.Lock(true); err != nil {
+		t.Fatalf("Failed to lock store, err:%v", err)
+	}
+	defer writer.Unlock(false)
+
+	writer.Write(testKey1, &testType1{"test", 1})
+
+	kvs, _ := NewBoltStore(fileName)
+
+	changes := make(chan []string, 10)
+	stop, err := kvs.Watch(func(keys []string) { changes <- keys })
+	if err != nil {
+		t.Fatalf("Failed to watch store, err:%v", err)
+	}
+	defer stop()
+
+	for i := 2; i <= 3; i++ {
+		writer.Write(testKey2, &testType1{"test", i})
+
+		keys := waitForChange(changes)
+		if !reflect.DeepEqual(keys, []string{testKey2}) {
+			t.Errorf("Watch reported keys %v after commit %v", keys, i)
+		}
+	}
+
+	// Rewriting a key with the same value is not reported.
+	writer.Write(testKey1, &testType1{"test", 1})
+	writer.Write(testKey1, &testType1{"test", 2})
+
+	keys := waitForChange(changes)
+	if !reflect.DeepEqual(keys, []string{testKey1}) {
+		t.Errorf("Watch reported keys %v after rewriting a key", keys)
+	}
+}
+
+// Tests that watchers poll the modification time of the store where notifications are not supported.
+func TestWatchPollsModificationTime(t *testing.T) {
+	defer removeStoreFiles(testFileName, DefaultBackupCount)
+
+	kvs, _ := NewJsonFileStore(testFileName)
+	kvs.Write(testKey1, &testType1{"test", 1})
+
+	changes := make(chan []string, 10)
+
+	w := &storeWatcher{
+		fileName: testFileName,
+		readAll:  kvs.(*jsonFileStore).readAll,
+		onChange: func(keys []string) { changes <- keys },
+	}
+
+	w.modTime = getModificationTime(testFileName)
+	w.values, _ = w.readAll()
+
+	stop := w.poll(10 * time.Millisecond)
+	defer stop()
+
+	// Make sure the modification time changes on file systems with coarse timestamps.
+	time.Sleep(10 * time.Millisecond)
+	os.Remove(testFileName)
+
+	keys := waitForChange(changes)
+	if !reflect.DeepEqual(keys, []string{testKey1}) {
+		t.Errorf("Poll reported keys %v after removing the store file", keys)
+	}
+}
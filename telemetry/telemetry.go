@@ -138,6 +138,25 @@ type CNSReport struct {
 	Metadata        Metadata `json:"compute"`
 }
 
+// Azure IPAM Telemetry Report structure.
+type IPAMReport struct {
+	IsNewInstance    bool
+	Context          string
+	EventMessage     string
+	ExhaustionReason string
+	AddressSpace     string
+	PoolID           string
+	Capacity         int
+	InUse            int
+	Unhealthy        int
+	Quarantined      int
+	HighWaterMark    int
+	AllocationRate   float64
+	ReleaseRate      float64
+	Timestamp        string
+	Metadata         Metadata `json:"compute"`
+}
+
 // ClusterState contains the current kubernetes cluster state.
 type ClusterState struct {
 	PodCount      int
@@ -393,6 +412,7 @@ func (reportMgr *ReportManager) ReportToBytes() ([]byte, error) {
 	case *NPMReport:
 	case *DNCReport:
 	case *CNSReport:
+	case *IPAMReport:
 	default:
 		err = fmt.Errorf("[Telemetry] Invalid report type")
 	}
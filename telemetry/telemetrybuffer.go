@@ -67,10 +67,11 @@ type TelemetryBuffer struct {
 
 // Buffer object holds the different types of reports
 type Buffer struct {
-	DNCReports []DNCReport
-	CNIReports []CNIReport
-	NPMReports []NPMReport
-	CNSReports []CNSReport
+	DNCReports  []DNCReport
+	CNIReports  []CNIReport
+	NPMReports  []NPMReport
+	CNSReports  []CNSReport
+	IPAMReports []IPAMReport
 }
 
 // NewTelemetryBuffer - create a new TelemetryBuffer
@@ -143,6 +144,10 @@ func (tb *TelemetryBuffer) StartServer() error {
 								var cnsReport CNSReport
 								json.Unmarshal([]byte(reportStr), &cnsReport)
 								tb.data <- cnsReport
+							} else if _, ok := tmp["ExhaustionReason"]; ok {
+								var ipamReport IPAMReport
+								json.Unmarshal([]byte(reportStr), &ipamReport)
+								tb.data <- ipamReport
 							}
 						} else {
 							var index int
@@ -279,18 +284,19 @@ func (tb *TelemetryBuffer) Close() {
 // sendToHost - send buffer to host
 func (tb *TelemetryBuffer) sendToHost() error {
 	buf := Buffer{
-		DNCReports: make([]DNCReport, 0),
-		CNIReports: make([]CNIReport, 0),
-		NPMReports: make([]NPMReport, 0),
-		CNSReports: make([]CNSReport, 0),
+		DNCReports:  make([]DNCReport, 0),
+		CNIReports:  make([]CNIReport, 0),
+		NPMReports:  make([]NPMReport, 0),
+		CNSReports:  make([]CNSReport, 0),
+		IPAMReports: make([]IPAMReport, 0),
 	}
 
 	seed := rand.NewSource(time.Now().UnixNano())
 	i, payloadSize, maxPayloadSizeReached := rand.New(seed).Intn(reflect.ValueOf(&buf).Elem().NumField()), 0, false
-	isDNCReportsEmpty, isCNIReportsEmpty, isCNSReportsEmpty, isNPMReportsEmpty := false, false, false, false
+	isDNCReportsEmpty, isCNIReportsEmpty, isCNSReportsEmpty, isNPMReportsEmpty, isIPAMReportsEmpty := false, false, false, false, false
 	for {
 		// craft payload in a round-robin manner.
-		switch i % 4 {
+		switch i % 5 {
 		case 0:
 			reportLen := len(tb.buffer.DNCReports)
 			if reportLen == 0 || isDNCReportsEmpty {
@@ -375,9 +381,30 @@ func (tb *TelemetryBuffer) sendToHost() error {
 			}
 			buf.NPMReports = append(buf.NPMReports, report)
 			tb.buffer.NPMReports = tb.buffer.NPMReports[1:]
+		case 4:
+			reportLen := len(tb.buffer.IPAMReports)
+			if reportLen == 0 || isIPAMReportsEmpty {
+				isIPAMReportsEmpty = true
+				break
+			}
+
+			if reportLen == 1 {
+				isIPAMReportsEmpty = true
+			}
+
+			report := tb.buffer.IPAMReports[0]
+			if bytes, err := json.Marshal(report); err == nil {
+				payloadSize += len(bytes)
+				if payloadSize > MaxPayloadSize {
+					maxPayloadSizeReached = true
+					break
+				}
+			}
+			buf.IPAMReports = append(buf.IPAMReports, report)
+			tb.buffer.IPAMReports = tb.buffer.IPAMReports[1:]
 		}
 
-		if isDNCReportsEmpty && isCNIReportsEmpty && isCNSReportsEmpty && isNPMReportsEmpty {
+		if isDNCReportsEmpty && isCNIReportsEmpty && isCNSReportsEmpty && isNPMReportsEmpty && isIPAMReportsEmpty {
 			break
 		}
 
@@ -450,6 +477,13 @@ func (buf *Buffer) push(x interface{}) {
 		cnsReport := x.(CNSReport)
 		cnsReport.Metadata = metadata
 		buf.CNSReports = append(buf.CNSReports, cnsReport)
+	case IPAMReport:
+		if len(buf.IPAMReports) >= MaxNumReports {
+			return
+		}
+		ipamReport := x.(IPAMReport)
+		ipamReport.Metadata = metadata
+		buf.IPAMReports = append(buf.IPAMReports, ipamReport)
 	}
 }
 
@@ -463,6 +497,8 @@ func (buf *Buffer) reset() {
 	buf.NPMReports = make([]NPMReport, 0)
 	buf.CNSReports = nil
 	buf.CNSReports = make([]CNSReport, 0)
+	buf.IPAMReports = nil
+	buf.IPAMReports = make([]IPAMReport, 0)
 	payloadSize = 0
 }
 
@@ -0,0 +1,76 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package watch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Directory events signaling a completely written file.
+	// Partial writes are ignored, as the file is reloaded once the writer closes it.
+	watchEvents = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE
+)
+
+// File watches a file with inotify and calls onChange after it is written, created or replaced.
+// The parent directory is watched, so that files replaced by rename are tracked too.
+// Returns a function that stops watching.
+func File(filePath string, onChange func()) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unix.InotifyAddWatch(fd, filepath.Dir(filePath), watchEvents)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// The non-blocking descriptor is serviced by the runtime poller, so closing it unblocks reads.
+	file := os.NewFile(uintptr(fd), "inotify")
+	name := filepath.Base(filePath)
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				log.Printf("[watch] Stopped watching %v, err:%v.", filePath, err)
+				return
+			}
+
+			changed := false
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + unix.SizeofInotifyEvent
+				end := start + int(event.Len)
+				if end > n {
+					break
+				}
+
+				eventName := string(bytes.TrimRight(buf[start:end], "\x00"))
+				if eventName == name && event.Mask&watchEvents != 0 {
+					changed = true
+				}
+
+				offset = end
+			}
+
+			if changed {
+				onChange()
+			}
+		}
+	}()
+
+	return func() { file.Close() }, nil
+}
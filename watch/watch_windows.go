@@ -0,0 +1,14 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package watch
+
+import (
+	"fmt"
+)
+
+// File watches a file and calls onChange after it is written.
+// File watching is not supported on Windows. Callers detect changes by modification time instead.
+func File(filePath string, onChange func()) (func(), error) {
+	return nil, fmt.Errorf("File watching is not supported")
+}